@@ -1,12 +1,15 @@
 package main
 
 import (
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aliostad/TraceView/tracing"
@@ -17,23 +20,72 @@ var (
 )
 
 type TraceApi struct {
-	config *tracing.Config
-	store  tracing.TraceStore
-	server *http.Server
+	config      *tracing.Config
+	store       tracing.TraceStore
+	ingester    *tracing.Ingester
+	filters     *tracing.FilterRules
+	highlighter *tracing.Highlighter
+	sessions    *tracing.Sessions
+	fields      *tracing.FieldCatalog
+	patterns    *tracing.PatternMiner
+	alerter     *tracing.Alerter
+	trustAlerts bool // rules posted to the API may run commands, notify and call webhooks
+	anomalies   *tracing.AnomalyDetector
+	broadcaster *tracing.Broadcaster
+	derived     []tracing.Resettable // reset when all traces are cleared
+	server      *http.Server
+	stopping    chan struct{} // closed on Stop so streams end
+}
+
+// a page of traces with links to the pages either side of it, Next is always set so it can be polled
+type TracePage struct {
+	Traces []*tracing.Trace
+	Next   string
+	Prev   string // empty when nothing comes before the page
+}
+
+// the page with runs of repeated traces folded into one, runs are not joined across pages
+type CollapsedPage struct {
+	Traces []*tracing.CollapsedTrace
+	Next   string
+	Prev   string
 }
 
 func NewTraceApi(port int,
 	address string,
 	config *tracing.Config,
-	store tracing.TraceStore) *TraceApi {
+	store tracing.TraceStore,
+	ingester *tracing.Ingester,
+	filters *tracing.FilterRules,
+	highlighter *tracing.Highlighter,
+	sessions *tracing.Sessions,
+	fields *tracing.FieldCatalog,
+	patterns *tracing.PatternMiner,
+	alerter *tracing.Alerter,
+	trustAlerts bool,
+	anomalies *tracing.AnomalyDetector,
+	broadcaster *tracing.Broadcaster,
+	derived []tracing.Resettable) *TraceApi {
 
 	if singletonApi != nil {
 		panic("Another TraceAPI already exists.")
 	}
 
 	singletonApi = &TraceApi{
-		config: config,
-		store:  store,
+		config:      config,
+		store:       store,
+		ingester:    ingester,
+		filters:     filters,
+		highlighter: highlighter,
+		sessions:    sessions,
+		fields:      fields,
+		patterns:    patterns,
+		alerter:     alerter,
+		trustAlerts: trustAlerts,
+		anomalies:   anomalies,
+		broadcaster: broadcaster,
+		derived:     derived,
+		stopping:    make(chan struct{}),
 		server: &http.Server{
 			Addr:    fmt.Sprintf("%s:%d", address, port),
 			Handler: nil, // to use default handler
@@ -47,6 +99,30 @@ func (api *TraceApi) Start() error {
 	fs := http.FileServer(http.Dir("./content"))
 
 	http.HandleFunc("/api/traces", traces)
+	http.HandleFunc("/api/waterfall", waterfall)
+	http.HandleFunc("/api/v2/spans", zipkinSpans)
+	http.HandleFunc("/api/filters", filters)
+	http.HandleFunc("/api/highlights", highlights)
+	http.HandleFunc("/api/sessions", sessions)
+	http.HandleFunc("/api/sessions/stop", stopSession)
+	http.HandleFunc("/api/sessions/traces", sessionTraces)
+	http.HandleFunc("/api/export", export)
+	http.HandleFunc("/api/import", importTraces)
+	http.HandleFunc("/api/ingest", ingestTraces)
+	http.HandleFunc("/api/capture", capture)
+	http.HandleFunc("/api/capture/pause", pauseCapture)
+	http.HandleFunc("/api/capture/resume", resumeCapture)
+	http.HandleFunc("/api/aggregate", aggregate)
+	http.HandleFunc("/api/metrics", metrics)
+	http.HandleFunc("/api/metrics/stats", metricStats)
+	http.HandleFunc("/api/fields", fields)
+	http.HandleFunc("/api/fields/validate", validateFields)
+	http.HandleFunc("/api/patterns", patterns)
+	http.HandleFunc("/api/diff", diff)
+	http.HandleFunc("/api/alerts", alerts)
+	http.HandleFunc("/api/alerts/history", alertHistory)
+	http.HandleFunc("/api/anomalies", anomalies)
+	http.HandleFunc("/api/stream", stream)
 	http.HandleFunc("/$", homePage)
 	http.Handle("/", fs)
 
@@ -59,21 +135,478 @@ func (api *TraceApi) Start() error {
 	return nil
 }
 
+// forgets what was built from the traces once all of them are cleared,
+// not when a session is deleted as the traces left still refer to it
+func (api *TraceApi) resetDerived() {
+	resetAll(api.derived)
+}
+
+// GET lists traces and DELETE clears all of them
 func traces(w http.ResponseWriter, r *http.Request) {
-	var to, from *time.Time
+	if r.Method == http.MethodDelete {
+		err := singletonApi.store.Clear()
+		if err != nil {
+			log.Println(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		singletonApi.resetDerived()
+
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
 	var n int
-	froms := r.URL.Query().Get("from")
-	tos := r.URL.Query().Get("to")
 	counts := r.URL.Query().Get("count")
+	from, to, err := parseTimeRange(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
 
-	if froms == "" {
-		from = nil
+	if counts == "" {
+		n = 100
 	} else {
-		fromX, err := time.Parse(time.RFC3339, froms)
+		n, _ = strconv.Atoi(counts)
+	}
+
+	match, err := parseMatch(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	collapse := r.URL.Query().Get("collapse")
+	if collapse != "" && collapse != tracing.CollapseMessage && collapse != tracing.CollapsePattern {
+		http.Error(w, "collapse is either message or pattern", http.StatusBadRequest)
+		return
+	}
+
+	var cursor *tracing.Cursor
+	backward := false
+	after := r.URL.Query().Get("after")
+	before := r.URL.Query().Get("before")
+	switch {
+	case after != "" && before != "":
+		http.Error(w, "after and before cannot be used together", http.StatusBadRequest)
+		return
+	case after != "":
+		cursor, err = tracing.DecodeCursor(after)
+	case before != "":
+		cursor, err = tracing.DecodeCursor(before)
+		backward = true
+	default:
+		// without a cursor or a start, the page is the latest traces
+		backward = from == nil
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := singletonApi.store.ListPage(n, from, to, match, cursor, backward)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	result := &TracePage{Traces: page.Traces}
+	if len(page.Traces) > 0 {
+		first := tracing.CursorOf(page.Traces[0])
+		result.Next = pageLink(r, "after", tracing.CursorOf(page.Traces[len(page.Traces)-1]))
+		hasPrev := backward && page.HasMore
+		if !backward {
+			prev, err := singletonApi.store.ListPage(1, from, to, match, first, true)
+			if err != nil {
+				log.Println(err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			hasPrev = len(prev.Traces) > 0
+		}
+		if hasPrev {
+			result.Prev = pageLink(r, "before", first)
+		}
+	} else if !backward && cursor != nil {
+		// nothing new yet, polling the same link picks up whatever arrives
+		result.Next = pageLink(r, "after", cursor)
+	} else {
+		// anything arriving after the end of the range comes next
+		result.Next = pageLink(r, "after", &tracing.Cursor{TimeIndex: strconv.FormatInt(to.UnixMicro(), 10)})
+	}
+
+	if collapse != "" {
+		writeJson(w, http.StatusOK, &CollapsedPage{
+			Traces: tracing.Collapse(result.Traces, collapse),
+			Next:   result.Next,
+			Prev:   result.Prev,
+		})
+		return
+	}
+
+	writeJson(w, http.StatusOK, result)
+}
+
+// the request's link with the cursor replacing any previous one
+func pageLink(r *http.Request, direction string, cursor *tracing.Cursor) string {
+	query := r.URL.Query()
+	query.Del("after")
+	query.Del("before")
+	query.Set(direction, cursor.Encode())
+	return r.URL.Path + "?" + query.Encode()
+}
+
+func waterfall(w http.ResponseWriter, r *http.Request) {
+	corrId := r.URL.Query().Get("correlationId")
+	if corrId == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	traces, err := singletonApi.store.ListByCorrelationId(corrId)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if len(traces) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tracing.BuildWaterfall(corrId, traces))
+}
+
+// accepts spans in Zipkin v2 JSON format so Zipkin reporters can point at TraceView
+func zipkinSpans(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	traces, payloads, err := tracing.ParseZipkinSpans(data)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	for i, trc := range traces {
+		trc.Source = r.RemoteAddr
+		err = singletonApi.ingester.Ingest(&tracing.Entry{Trace: trc, Payload: payloads[i]})
+		if err != nil {
+			log.Println(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// GET lists the rules with their drop counts, POST adds, PUT replaces and DELETE removes a rule by id
+func filters(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJson(w, http.StatusOK, singletonApi.filters.Summary())
+	case http.MethodPost:
+		var rule tracing.FilterRule
+		err := json.NewDecoder(r.Body).Decode(&rule)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		added, err := singletonApi.filters.Add(&rule)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeJson(w, http.StatusCreated, added)
+	case http.MethodPut:
+		var rule tracing.FilterRule
+		err := json.NewDecoder(r.Body).Decode(&rule)
 		if err != nil {
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
+
+		found, err := singletonApi.filters.Update(&rule)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		writeJson(w, http.StatusOK, &rule)
+	case http.MethodDelete:
+		if !singletonApi.filters.Remove(r.URL.Query().Get("id")) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// GET lists the rules, POST adds, PUT replaces and DELETE removes a rule by id
+func highlights(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJson(w, http.StatusOK, singletonApi.highlighter.List())
+	case http.MethodPost:
+		var rule tracing.HighlightRule
+		err := json.NewDecoder(r.Body).Decode(&rule)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		added, err := singletonApi.highlighter.Add(&rule)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeJson(w, http.StatusCreated, added)
+	case http.MethodPut:
+		var rule tracing.HighlightRule
+		err := json.NewDecoder(r.Body).Decode(&rule)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		found, err := singletonApi.highlighter.Update(&rule)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		writeJson(w, http.StatusOK, &rule)
+	case http.MethodDelete:
+		found, err := singletonApi.highlighter.Remove(r.URL.Query().Get("id"))
+		if err != nil {
+			log.Println(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// GET lists the sessions, POST starts a new one, PUT renames and DELETE removes a session by id
+func sessions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		list, err := singletonApi.sessions.List()
+		if err != nil {
+			log.Println(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		writeJson(w, http.StatusOK, list)
+	case http.MethodPost, http.MethodPut:
+		var body tracing.Session
+		if r.ContentLength != 0 {
+			err := json.NewDecoder(r.Body).Decode(&body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+
+		var session *tracing.Session
+		var err error
+		status := http.StatusOK
+		if r.Method == http.MethodPost {
+			session, err = singletonApi.sessions.Start(body.Name)
+			status = http.StatusCreated
+		} else {
+			session, err = singletonApi.sessions.Rename(r.URL.Query().Get("id"), body.Name)
+		}
+
+		if err == tracing.ErrSessionNotFound {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if err == tracing.ErrSessionNameEmpty {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err != nil {
+			log.Println(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		writeJson(w, status, session)
+	case http.MethodDelete:
+		err := singletonApi.sessions.Delete(r.URL.Query().Get("id"))
+		if err == tracing.ErrSessionNotFound {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if err != nil {
+			log.Println(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// stops the running session
+func stopSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	session, err := singletonApi.sessions.Stop()
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if session == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	writeJson(w, http.StatusOK, session)
+}
+
+func sessionTraces(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	session, err := singletonApi.store.GetSession(id)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if session == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	traces, err := singletonApi.store.ListBySessionId(id)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writeJson(w, http.StatusOK, traces)
+}
+
+func capture(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, http.StatusOK, singletonApi.ingester.Status())
+}
+
+// pauses capturing, buffering incoming traces unless mode=drop
+func pauseCapture(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = tracing.PauseBuffer
+	}
+
+	err := singletonApi.ingester.Pause(mode)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJson(w, http.StatusOK, singletonApi.ingester.Status())
+}
+
+func resumeCapture(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := singletonApi.ingester.Resume()
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writeJson(w, http.StatusOK, singletonApi.ingester.Status())
+}
+
+// reads from and to as RFC3339, with to defaulting to now
+func parseTimeRange(r *http.Request) (*time.Time, *time.Time, error) {
+	var to, from *time.Time
+	froms := r.URL.Query().Get("from")
+	tos := r.URL.Query().Get("to")
+
+	if froms != "" {
+		fromX, err := time.Parse(time.RFC3339, froms)
+		if err != nil {
+			return nil, nil, err
+		}
 		from = &fromX
 	}
 
@@ -81,32 +614,61 @@ func traces(w http.ResponseWriter, r *http.Request) {
 		toX := time.Now().UTC()
 		to = &toX
 	} else {
-		toX, err := time.Parse(time.RFC3339, froms)
+		toX, err := time.Parse(time.RFC3339, tos)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			return
+			return nil, nil, err
 		}
 		to = &toX
 	}
 
-	if counts == "" {
-		n = 100
-	} else {
-		n, _ = strconv.Atoi(counts)
+	return from, to, nil
+}
+
+// builds a match from the query string: level, highlight and pattern are comma separated,
+// message, source and prop.<name> are patterns and where is an expression
+func parseMatch(r *http.Request) (*tracing.Match, error) {
+	query := r.URL.Query()
+	match := &tracing.Match{
+		Levels:         splitQuery(query.Get("level")),
+		MessagePattern: query.Get("message"),
+		SourcePattern:  query.Get("source"),
+		Highlights:     splitQuery(query.Get("highlight")),
+		Expression:     query.Get("where"),
+		SessionId:      query.Get("session"),
+		CorrelationId:  query.Get("correlationId"),
+		PatternIds:     splitQuery(query.Get("pattern")),
+		Properties:     make(map[string]string),
+	}
+
+	for key, values := range query {
+		if strings.HasPrefix(key, "prop.") && len(values) > 0 {
+			match.Properties[strings.TrimPrefix(key, "prop.")] = values[0]
+		}
 	}
 
-	traces, err := singletonApi.store.ListByTimeRange(n, from, to, true)
+	err := match.Compile()
 	if err != nil {
-		log.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return nil, err
+	}
+
+	return match, nil
+}
+
+func splitQuery(value string) []string {
+	if value == "" {
+		return nil
 	}
+	return strings.Split(value, ",")
+}
 
+func writeJson(w http.ResponseWriter, status int, value interface{}) {
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(traces)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
 }
 
 func (api *TraceApi) Stop(ctx context.Context) error {
+	close(api.stopping)
 	return api.server.Shutdown(ctx)
 }
 
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aliostad/TraceView/tracing"
+)
+
+// counts traces satisfying the same filters as /api/traces in time buckets. bucket is a duration
+// such as 1m, by is the field to group the counts by and top the field to list the most frequent
+// values of, n of them
+func aggregate(w http.ResponseWriter, r *http.Request) {
+	from, to, err := parseTimeRange(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	match, err := parseMatch(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query := &tracing.AggregateQuery{
+		From:     from,
+		To:       to,
+		Match:    match,
+		GroupBy:  r.URL.Query().Get("by"),
+		TopField: r.URL.Query().Get("top"),
+	}
+
+	query.Bucket, err = parseBucket(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if ns := r.URL.Query().Get("n"); ns != "" {
+		query.TopN, err = strconv.Atoi(ns)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	aggregation, err := tracing.Aggregate(singletonApi.store, query)
+	if err == tracing.ErrTooManyBuckets {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writeJson(w, http.StatusOK, aggregation)
+}
+
+func parseBucket(r *http.Request) (time.Duration, error) {
+	buckets := r.URL.Query().Get("bucket")
+	if buckets == "" {
+		return 0, nil
+	}
+
+	return time.ParseDuration(buckets)
+}
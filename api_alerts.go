@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/aliostad/TraceView/tracing"
+)
+
+func alerts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJson(w, http.StatusOK, singletonApi.alerter.List())
+	case http.MethodPost:
+		var rule tracing.AlertRule
+		err := json.NewDecoder(r.Body).Decode(&rule)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if !singletonApi.trustAlerts {
+			err = tracing.CheckRemoteAlertRule(&rule)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		added, err := singletonApi.alerter.Add(&rule)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeJson(w, http.StatusCreated, added)
+	case http.MethodPut:
+		var rule tracing.AlertRule
+		err := json.NewDecoder(r.Body).Decode(&rule)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if !singletonApi.trustAlerts {
+			err = tracing.CheckRemoteAlertRule(&rule)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		found, err := singletonApi.alerter.Update(&rule)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		writeJson(w, http.StatusOK, &rule)
+	case http.MethodDelete:
+		if !singletonApi.alerter.Remove(r.URL.Query().Get("id")) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// the alerts fired, latest first
+func alertHistory(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, http.StatusOK, singletonApi.alerter.History())
+}
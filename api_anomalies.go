@@ -0,0 +1,18 @@
+package main
+
+import (
+	"net/http"
+)
+
+// lists the rate anomalies, latest first, or forgets the learned rates on DELETE
+func anomalies(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJson(w, http.StatusOK, singletonApi.anomalies.Anomalies())
+	case http.MethodDelete:
+		singletonApi.anomalies.Reset()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
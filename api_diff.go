@@ -0,0 +1,50 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/aliostad/TraceView/tracing"
+)
+
+// diffs the traces of the left and right correlation ids, or sessions when by=session
+func diff(w http.ResponseWriter, r *http.Request) {
+	leftId := r.URL.Query().Get("left")
+	rightId := r.URL.Query().Get("right")
+	if leftId == "" || rightId == "" {
+		http.Error(w, "left and right are required", http.StatusBadRequest)
+		return
+	}
+
+	list := singletonApi.store.ListByCorrelationId
+	switch r.URL.Query().Get("by") {
+	case "", "correlation":
+	case "session":
+		list = singletonApi.store.ListBySessionId
+	default:
+		http.Error(w, "by is either correlation or session", http.StatusBadRequest)
+		return
+	}
+
+	left, err := list(leftId)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	right, err := list(rightId)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	result, err := tracing.DiffTraces(left, right)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJson(w, http.StatusOK, result)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/aliostad/TraceView/tracing"
+)
+
+const export_flush_every = 100
+
+// streams every trace satisfying the same filters as /api/traces, without a cap on the count
+func export(w http.ResponseWriter, r *http.Request) {
+	from, to, err := parseTimeRange(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	match, err := parseMatch(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = tracing.ExportNdjson
+	}
+
+	writer, err := tracing.NewExportWriter(format, w, splitQuery(r.URL.Query().Get("columns")))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", tracing.ExportContentType(format))
+	w.Header().Set("Content-Disposition", "attachment; filename=traces."+format)
+	flusher, canFlush := w.(http.Flusher)
+	count := 0
+	var writeErr error
+	err = singletonApi.store.Walk(from, to, match, func(trc *tracing.Trace) bool {
+		writeErr = writer.Write(trc)
+		if writeErr != nil {
+			return false
+		}
+
+		count++
+		if canFlush && count%export_flush_every == 0 {
+			flusher.Flush()
+		}
+		return true
+	})
+
+	if err == nil {
+		err = writeErr
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+
+	// headers are gone by now so the export can only be cut short
+	if err != nil {
+		log.Println(err)
+	}
+}
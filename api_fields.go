@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/aliostad/TraceView/tracing"
+)
+
+type FieldValidation struct {
+	Valid   bool
+	Error   string
+	Unknown []string // fields never seen, the expression is still valid
+}
+
+// lists the catalog of properties and metrics, optionally of a kind and with names starting with prefix
+func fields(w http.ResponseWriter, r *http.Request) {
+	kind := r.URL.Query().Get("kind")
+	prefix := r.URL.Query().Get("prefix")
+	result := make([]*tracing.FieldInfo, 0)
+	for _, field := range singletonApi.fields.List() {
+		if kind != "" && field.Kind != kind {
+			continue
+		}
+		if prefix != "" && !strings.HasPrefix(field.Name, prefix) &&
+			!strings.HasPrefix(field.Name, "Properties."+prefix) && !strings.HasPrefix(field.Name, "Metrics."+prefix) {
+			continue
+		}
+		result = append(result, field)
+	}
+
+	writeJson(w, http.StatusOK, result)
+}
+
+// checks the where expression compiles and only uses fields in the catalog
+func validateFields(w http.ResponseWriter, r *http.Request) {
+	expression, err := tracing.CompileExpression(r.URL.Query().Get("where"))
+	if err != nil {
+		writeJson(w, http.StatusOK, &FieldValidation{Error: err.Error(), Unknown: make([]string, 0)})
+		return
+	}
+
+	writeJson(w, http.StatusOK, &FieldValidation{
+		Valid:   true,
+		Unknown: singletonApi.fields.Unknown(expression.Fields()),
+	})
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aliostad/TraceView/tracing"
+)
+
+// imports the body into a new session named after name, or into the session given by session
+func importTraces(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	err := tracing.CheckImportFormat(query.Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sessionId := query.Get("session")
+	created := sessionId == ""
+	if created {
+		name := query.Get("name")
+		if name == "" {
+			name = "import " + time.Now().UTC().Format(time.RFC3339)
+		}
+
+		session, err := singletonApi.sessions.Create(name)
+		if err != nil {
+			log.Println(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		sessionId = session.Id
+	} else {
+		session, err := singletonApi.store.GetSession(sessionId)
+		if err != nil {
+			log.Println(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if session == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+	}
+
+	source := "import"
+	if query.Get("source") != "" {
+		source = "import:" + query.Get("source")
+	}
+
+	parser := tracing.NewPayloadParserWithConfig(singletonApi.config)
+	result, err := tracing.Import(query.Get("format"), r.Body, parser, singletonApi.ingester.IngestImported, source, sessionId)
+	if err != nil {
+		if created {
+			// nothing is left of an import which could not be read
+			singletonApi.sessions.Delete(sessionId)
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJson(w, http.StatusOK, result)
+}
+
+type IngestResult struct {
+	Ingested int
+	Failed   int // lines which could not be parsed or ingested
+}
+
+// ingests the lines of the body as payloads, the same as if they arrived over UDP
+func ingestTraces(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	result := &IngestResult{}
+	parser := tracing.NewPayloadParserWithConfig(singletonApi.config)
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 64*1024), max_line_size)
+	for scanner.Scan() {
+		payload := strings.TrimSpace(scanner.Text())
+		if payload == "" {
+			continue
+		}
+
+		trc, err := parser.Parse(payload)
+		if err == nil {
+			trc.Source = r.RemoteAddr
+			err = singletonApi.ingester.Ingest(&tracing.Entry{Trace: trc, Payload: payload})
+		}
+		if err != nil {
+			result.Failed++
+			continue
+		}
+		result.Ingested++
+	}
+
+	if err := scanner.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJson(w, http.StatusOK, result)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/aliostad/TraceView/tracing"
+)
+
+type PatternDetail struct {
+	Pattern *tracing.Pattern
+	Traces  []*tracing.Trace // the examples still in the store
+}
+
+// lists the message patterns, most frequent first and up to count of them,
+// or returns the pattern of the id with its example traces
+func patterns(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		list := singletonApi.patterns.List()
+		if counts := r.URL.Query().Get("count"); counts != "" {
+			n, err := strconv.Atoi(counts)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if n >= 0 && n < len(list) {
+				list = list[:n]
+			}
+		}
+
+		writeJson(w, http.StatusOK, list)
+		return
+	}
+
+	pattern := singletonApi.patterns.Get(id)
+	if pattern == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	detail := &PatternDetail{Pattern: pattern, Traces: make([]*tracing.Trace, 0)}
+	for _, traceId := range pattern.Examples {
+		trc, err := singletonApi.store.GetById(traceId)
+		if err != nil {
+			log.Println(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if trc != nil {
+			detail.Traces = append(detail.Traces, trc)
+		}
+	}
+
+	writeJson(w, http.StatusOK, detail)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/aliostad/TraceView/tracing"
+)
+
+const stream_heartbeat = 15 * time.Second
+
+// streams the traces satisfying the same filters as /api/traces as server-sent events while they arrive,
+// after replaying those since from if it is given
+func stream(w http.ResponseWriter, r *http.Request) {
+	match, err := parseMatch(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var from *time.Time
+	if froms := r.URL.Query().Get("from"); froms != "" {
+		fromX, err := time.Parse(time.RFC3339, froms)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		from = &fromX
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// subscribing ahead of the replay so nothing arriving in between is missed
+	subscription := singletonApi.broadcaster.Subscribe(match)
+	defer singletonApi.broadcaster.Unsubscribe(subscription)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	var replayed map[string]bool
+	if from != nil {
+		replayed = make(map[string]bool)
+		err = singletonApi.store.Walk(from, nil, match, func(trc *tracing.Trace) bool {
+			replayed[trc.TraceId] = true
+			err = writeEvent(w, trc)
+			return err == nil
+		})
+
+		if err != nil {
+			log.Println(err)
+			return
+		}
+	}
+
+	flusher.Flush()
+
+	// only traces already waiting can have been replayed too
+	waiting := len(subscription.C)
+	heartbeat := time.NewTicker(stream_heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-singletonApi.stopping:
+			return
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(w, ": ping\n\n")
+		case trc := <-subscription.C:
+			if waiting > 0 {
+				waiting--
+				skip := replayed[trc.TraceId]
+				if waiting == 0 {
+					replayed = nil
+				}
+				if skip {
+					continue
+				}
+			}
+			err = writeEvent(w, trc)
+		}
+
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func writeEvent(w http.ResponseWriter, trc *tracing.Trace) error {
+	data, err := json.Marshal(trc)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "event: trace\ndata: %s\n\n", data)
+	return err
+}
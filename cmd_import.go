@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/aliostad/TraceView/tracing"
+)
+
+// traceview import [flags] file... sends files to a running instance, all into one new session
+func runImport(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	urlPtr := flags.String("url", "http://localhost:8969", "URL of the running TraceView")
+	formatPtr := flags.String("format", tracing.ImportAuto, "auto, ndjson, clef, otlp or text")
+	namePtr := flags.String("name", "", "name of the new session, defaults to the first file name")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		fmt.Println("Usage: traceview import [flags] file...")
+		flags.PrintDefaults()
+		os.Exit(2)
+	}
+
+	name := *namePtr
+	if name == "" {
+		name = filepath.Base(flags.Arg(0))
+	}
+
+	sessionId := ""
+	for _, path := range flags.Args() {
+		result, err := importFile(*urlPtr, path, *formatPtr, name, sessionId)
+		handleErrorNot(err)
+		sessionId = result.SessionId
+		fmt.Printf("%s: imported %d, failed %d\n", path, result.Imported, result.Failed)
+	}
+
+	fmt.Println("Session: ", sessionId)
+}
+
+func importFile(baseUrl string, path string, format string, name string, sessionId string) (*tracing.ImportResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	query := url.Values{}
+	query.Set("format", format)
+	query.Set("source", filepath.Base(path))
+	if sessionId == "" {
+		query.Set("name", name)
+	} else {
+		query.Set("session", sessionId)
+	}
+
+	resp, err := http.Post(baseUrl+"/api/import?"+query.Encode(), "application/octet-stream", file)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("import of %s failed with %s", path, resp.Status)
+	}
+
+	var result tracing.ImportResult
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
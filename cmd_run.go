@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// a datagram carries up to 64KB, lines are split so even escaped into JSON they fit
+const max_udp_line = 8 * 1024
+
+// traceview run [flags] -- command [args...] runs the command and sends every line it writes to a running instance.
+// All the traces of a run share a correlation id, the last one records how the command exited.
+func runRun(args []string) {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	protoPtr := flags.String("proto", "udp", "udp, tcp or http, which sends everything once the command exits")
+	addrPtr := flags.String("addr", "localhost:1969", "address of the running TraceView for udp and tcp")
+	urlPtr := flags.String("url", "http://localhost:8969", "URL of the running TraceView for http")
+	stdoutLevelPtr := flags.String("stdout-level", "info", "level of the lines written to stdout")
+	stderrLevelPtr := flags.String("stderr-level", "warn", "level of the lines written to stderr")
+	quietPtr := flags.Bool("quiet", false, "do not pass the output of the command through")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		fmt.Println("Usage: traceview run [flags] -- command [args...]")
+		flags.PrintDefaults()
+		os.Exit(2)
+	}
+
+	client, err := newSender(*protoPtr, *addrPtr, *urlPtr)
+	handleErrorNot(err)
+
+	cmd := exec.Command(flags.Arg(0), flags.Args()[1:]...)
+	cmd.Stdin = os.Stdin
+	stdout, err := cmd.StdoutPipe()
+	handleErrorNot(err)
+	stderr, err := cmd.StderrPipe()
+	handleErrorNot(err)
+
+	// the command gets the interrupts itself, they should not stop the run before it can record the exit
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	started := time.Now()
+	err = cmd.Start()
+	handleErrorNot(err)
+	go func() {
+		for sig := range signals {
+			cmd.Process.Signal(sig)
+		}
+	}()
+
+	run := &commandRun{
+		client:        client,
+		maxLine:       maxLine(*protoPtr),
+		command:       strings.Join(flags.Args(), " "),
+		pid:           strconv.Itoa(cmd.Process.Pid),
+		correlationId: uuid.New().String(),
+	}
+
+	var readers sync.WaitGroup
+	readers.Add(2)
+	go func() {
+		defer readers.Done()
+		run.capture(stdout, "stdout", *stdoutLevelPtr, passThrough(os.Stdout, *quietPtr))
+	}()
+	go func() {
+		defer readers.Done()
+		run.capture(stderr, "stderr", *stderrLevelPtr, passThrough(os.Stderr, *quietPtr))
+	}()
+
+	// the pipes are closed by Wait so they have to be read to the end first
+	readers.Wait()
+	err = cmd.Wait()
+	duration := time.Since(started)
+	exitCode := cmd.ProcessState.ExitCode()
+	if _, exited := err.(*exec.ExitError); err != nil && !exited {
+		handleErrorNot(err)
+	}
+
+	level := "info"
+	message := fmt.Sprintf("%s exited with %d after %s", run.command, exitCode, duration.Round(time.Millisecond))
+	killedBy := ""
+	if status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		// exits the way shells report a command killed by a signal
+		killedBy = status.Signal().String()
+		exitCode = 128 + int(status.Signal())
+		message = fmt.Sprintf("%s was killed by signal %d (%s) after %s",
+			run.command, status.Signal(), killedBy, duration.Round(time.Millisecond))
+	}
+	if exitCode != 0 {
+		level = "error"
+	}
+
+	payload := run.payload(time.Now(), message, "exit", level)
+	payload["exitCode"] = exitCode
+	if killedBy != "" {
+		payload["signal"] = killedBy
+	}
+	payload["durationMs"] = float64(duration.Microseconds()) / 1000
+	run.send(payload)
+	if err = client.Close(); run.err == nil {
+		run.err = err
+	}
+
+	// the exit code is what scripts depend on, so failing to send does not change it
+	if run.err != nil {
+		fmt.Fprintln(os.Stderr, "Could not send the output:", run.err.Error())
+	}
+	os.Exit(exitCode)
+}
+
+// how long a line sent as one trace can be, 0 for any length
+func maxLine(proto string) int {
+	if proto == "udp" {
+		return max_udp_line
+	}
+	return 0
+}
+
+func passThrough(w io.Writer, quiet bool) io.Writer {
+	if quiet {
+		return io.Discard
+	}
+	return w
+}
+
+// sends the output of a command, from the readers of both its streams
+type commandRun struct {
+	lock          sync.Mutex
+	client        sender
+	command       string
+	pid           string
+	correlationId string
+	maxLine       int   // longer lines are sent in parts
+	err           error // the first sending failure, after which nothing more is sent
+}
+
+func (run *commandRun) capture(r io.Reader, stream string, level string, echo io.Writer) {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			io.WriteString(echo, line)
+			line = strings.TrimRight(line, "\r\n")
+			if strings.TrimSpace(line) != "" {
+				for _, part := range splitLine(line, run.maxLine) {
+					run.send(run.payload(time.Now(), part, stream, level))
+				}
+			}
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// splits the line into parts of up to size bytes, without splitting a character
+func splitLine(line string, size int) []string {
+	if size <= 0 || len(line) <= size {
+		return []string{line}
+	}
+
+	parts := make([]string, 0, len(line)/size+1)
+	for len(line) > size {
+		end := size
+		for end > 0 && !utf8.RuneStart(line[end]) {
+			end--
+		}
+		if end == 0 {
+			end = size
+		}
+		parts = append(parts, line[:end])
+		line = line[end:]
+	}
+	return append(parts, line)
+}
+
+// a CLEF payload, so the time the line was written and the correlation id survive parsing
+func (run *commandRun) payload(timestamp time.Time, message string, stream string, level string) map[string]interface{} {
+	return map[string]interface{}{
+		"@t":      timestamp.UTC().Format(time.RFC3339Nano),
+		"@m":      message,
+		"@l":      level,
+		"@tr":     run.correlationId,
+		"stream":  stream,
+		"pid":     run.pid,
+		"command": run.command,
+	}
+}
+
+func (run *commandRun) send(payload map[string]interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+
+	run.lock.Lock()
+	defer run.lock.Unlock()
+	if run.err == nil {
+		run.err = run.client.Send(string(data))
+	}
+}
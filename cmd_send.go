@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// sends payloads to a running instance over one of the protocols it listens on
+type sender interface {
+	Send(payload string) error
+	Close() error
+}
+
+// traceview send [flags] message... sends a message or JSON payload, or with -file every line of a file
+func runSend(args []string) {
+	flags := flag.NewFlagSet("send", flag.ExitOnError)
+	protoPtr := flags.String("proto", "udp", "udp, tcp or http")
+	addrPtr := flags.String("addr", "localhost:1969", "address of the running TraceView for udp and tcp")
+	urlPtr := flags.String("url", "http://localhost:8969", "URL of the running TraceView for http")
+	filePtr := flags.String("file", "", "sends every line of the file, - for standard input")
+	levelPtr := flags.String("level", "", "level of plain text messages, which are otherwise sent as they are")
+	flags.Parse(args)
+
+	if (*filePtr == "") == (flags.NArg() == 0) {
+		fmt.Println("Usage: traceview send [flags] message... or traceview send [flags] -file path")
+		flags.PrintDefaults()
+		os.Exit(2)
+	}
+
+	client, err := newSender(*protoPtr, *addrPtr, *urlPtr)
+	handleErrorNot(err)
+
+	send := func(payload string) {
+		err := client.Send(withLevel(payload, *levelPtr))
+		handleErrorNot(err)
+	}
+
+	if *filePtr == "" {
+		send(strings.Join(flags.Args(), " "))
+	} else {
+		file := os.Stdin
+		if *filePtr != "-" {
+			file, err = os.Open(*filePtr)
+			handleErrorNot(err)
+			defer file.Close()
+		}
+
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), max_line_size)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				send(line)
+			}
+		}
+		handleErrorNot(scanner.Err())
+	}
+
+	handleErrorNot(client.Close())
+}
+
+func newSender(proto string, addr string, url string) (sender, error) {
+	switch proto {
+	case "udp", "tcp":
+		return newConnSender(proto, addr)
+	case "http":
+		return &httpSender{url: url}, nil
+	}
+	return nil, fmt.Errorf("unknown -proto %q", proto)
+}
+
+// turns a plain text message into JSON carrying the level, JSON payloads are left alone
+func withLevel(payload string, level string) string {
+	if level == "" || strings.HasPrefix(strings.TrimSpace(payload), "{") {
+		return payload
+	}
+
+	data, _ := json.Marshal(map[string]string{"message": payload, "level": level})
+	return string(data)
+}
+
+// a datagram per payload over udp, a line per payload over tcp
+type connSender struct {
+	proto  string
+	conn   net.Conn
+	writer *bufio.Writer
+}
+
+func newConnSender(proto string, addr string) (*connSender, error) {
+	conn, err := net.Dial(proto, addr)
+	if err != nil {
+		return nil, err
+	}
+	return &connSender{proto: proto, conn: conn, writer: bufio.NewWriter(conn)}, nil
+}
+
+func (client *connSender) Send(payload string) error {
+	if client.proto == "udp" {
+		_, err := client.conn.Write([]byte(payload))
+		return err
+	}
+
+	// lines are what delimits payloads
+	_, err := client.writer.WriteString(strings.ReplaceAll(payload, "\n", " ") + "\n")
+	return err
+}
+
+func (client *connSender) Close() error {
+	err := client.writer.Flush()
+	if err != nil {
+		client.conn.Close()
+		return err
+	}
+	return client.conn.Close()
+}
+
+// collects the payloads and posts them in one request on Close
+type httpSender struct {
+	url  string
+	body bytes.Buffer
+}
+
+func (client *httpSender) Send(payload string) error {
+	client.body.WriteString(strings.ReplaceAll(payload, "\n", " ") + "\n")
+	return nil
+}
+
+func (client *httpSender) Close() error {
+	resp, err := http.Post(client.url+"/api/ingest", "text/plain", &client.body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("sending failed with %s", resp.Status)
+	}
+
+	var result IngestResult
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return err
+	}
+
+	if result.Failed > 0 {
+		return fmt.Errorf("%d of %d payloads could not be ingested", result.Failed, result.Failed+result.Ingested)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/aliostad/TraceView/tracing"
+)
+
+const max_event_size = 16 * 1024 * 1024
+
+var levelColors = map[string]string{
+	"fatal":       "\x1b[1;31m",
+	"critical":    "\x1b[1;31m",
+	"error":       "\x1b[31m",
+	"warn":        "\x1b[33m",
+	"warning":     "\x1b[33m",
+	"info":        "\x1b[32m",
+	"information": "\x1b[32m",
+	"debug":       "\x1b[90m",
+	"verbose":     "\x1b[90m",
+	"trace":       "\x1b[90m",
+}
+
+const (
+	colorDim   = "\x1b[2m"
+	colorReset = "\x1b[0m"
+)
+
+// traceview tail [flags] prints the traces of a running instance as they arrive
+func runTail(args []string) {
+	flags := flag.NewFlagSet("tail", flag.ExitOnError)
+	urlPtr := flags.String("url", "http://localhost:8969", "URL of the running TraceView")
+	levelPtr := flags.String("level", "", "levels to show, comma separated")
+	messagePtr := flags.String("message", "", "pattern the message has to match")
+	sourcePtr := flags.String("source", "", "pattern the source has to match")
+	wherePtr := flags.String("where", "", "expression the traces have to satisfy, e.g. Metrics.took_ms > 500")
+	correlationPtr := flags.String("correlation", "", "only follow the traces of this correlation id")
+	fromPtr := flags.String("from", "", "first show the traces since this time, RFC3339 or a duration ago such as 5m")
+	jsonPtr := flags.Bool("json", false, "print every trace as a line of JSON")
+	templatePtr := flags.String("template", "", "Go template of a line, e.g. '{{.Timestamp}} {{.Message}} {{index .Properties \"user\"}}'")
+	noColorPtr := flags.Bool("no-color", false, "do not colorize levels")
+	flags.Parse(args)
+
+	query := url.Values{}
+	setQuery(query, "level", *levelPtr)
+	setQuery(query, "message", *messagePtr)
+	setQuery(query, "source", *sourcePtr)
+	setQuery(query, "where", *wherePtr)
+	setQuery(query, "correlationId", *correlationPtr)
+	if *fromPtr != "" {
+		from, err := parseFrom(*fromPtr)
+		handleErrorNot(err)
+		query.Set("from", from.Format(time.RFC3339))
+	}
+
+	var lineTemplate *template.Template
+	if *templatePtr != "" {
+		var err error
+		lineTemplate, err = template.New("line").Parse(*templatePtr)
+		handleErrorNot(err)
+	}
+
+	color := !*noColorPtr && os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout)
+	resp, err := http.Get(*urlPtr + "/api/stream?" + query.Encode())
+	handleErrorNot(err)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "tail failed with %s: %s\n", resp.Status, strings.TrimSpace(string(body)))
+		os.Exit(1)
+	}
+
+	err = readEvents(resp.Body, func(data string) error {
+		if *jsonPtr {
+			_, err := fmt.Println(data)
+			return err
+		}
+
+		var trc tracing.Trace
+		err := json.Unmarshal([]byte(data), &trc)
+		if err != nil {
+			return err
+		}
+		return printTrace(os.Stdout, &trc, lineTemplate, color)
+	})
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	fmt.Fprintln(os.Stderr, "stream ended")
+	os.Exit(1)
+}
+
+// calls fn with the data of every server-sent event read
+func readEvents(r io.Reader, fn func(data string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), max_event_size)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		err := fn(strings.TrimPrefix(line, "data: "))
+		if err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func printTrace(w io.Writer, trc *tracing.Trace, lineTemplate *template.Template, color bool) error {
+	if lineTemplate != nil {
+		err := lineTemplate.Execute(w, trc)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w)
+		return err
+	}
+
+	level := fmt.Sprintf("%-5s", strings.ToUpper(trc.Level))
+	line := trc.Timestamp.Local().Format("2006-01-02 15:04:05.000") + " "
+	if code, ok := levelColors[strings.ToLower(trc.Level)]; ok && color {
+		line += code + level + colorReset
+	} else {
+		line += level
+	}
+
+	line += " " + trc.Message
+	if trc.CorrelationId != "" {
+		if color {
+			line += " " + colorDim + trc.CorrelationId + colorReset
+		} else {
+			line += " [" + trc.CorrelationId + "]"
+		}
+	}
+
+	_, err := fmt.Fprintln(w, line)
+	return err
+}
+
+func setQuery(query url.Values, name string, value string) {
+	if value != "" {
+		query.Set(name, value)
+	}
+}
+
+// RFC3339 or a duration before now
+func parseFrom(s string) (time.Time, error) {
+	if ago, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-ago), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/aliostad/TraceView/tracing"
+)
+
+// where the terminal UI gets its traces from, the store in-process or a remote instance's API
+type traceSource interface {
+	Latest(n int) ([]*tracing.Trace, error)
+	// sends the traces since the time, then the live ones until the context is done
+	Follow(ctx context.Context, since time.Time, traces chan<- *tracing.Trace) error
+	ByCorrelationId(corrId string) ([]*tracing.Trace, error)
+}
+
+// traceview tui [flags] runs the terminal UI against a running instance
+func runTui(args []string) {
+	flags := flag.NewFlagSet("tui", flag.ExitOnError)
+	urlPtr := flags.String("url", "http://localhost:8969", "URL of the running TraceView")
+	flags.Parse(args)
+
+	err := newTui(&remoteSource{url: *urlPtr}).run()
+	handleErrorNot(err)
+}
+
+type localSource struct {
+	store       tracing.TraceStore
+	broadcaster *tracing.Broadcaster
+}
+
+func (source *localSource) Latest(n int) ([]*tracing.Trace, error) {
+	page, err := source.store.ListPage(n, nil, nil, nil, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	return page.Traces, nil
+}
+
+func (source *localSource) Follow(ctx context.Context, since time.Time, traces chan<- *tracing.Trace) error {
+	subscription := source.broadcaster.Subscribe(nil)
+	defer source.broadcaster.Unsubscribe(subscription)
+	err := source.store.Walk(&since, nil, nil, func(trc *tracing.Trace) bool {
+		select {
+		case traces <- trc:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case trc := <-subscription.C:
+			select {
+			case traces <- trc:
+			case <-ctx.Done():
+				return nil
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (source *localSource) ByCorrelationId(corrId string) ([]*tracing.Trace, error) {
+	return source.store.ListByCorrelationId(corrId)
+}
+
+type remoteSource struct {
+	url string
+}
+
+func (source *remoteSource) Latest(n int) ([]*tracing.Trace, error) {
+	resp, err := http.Get(fmt.Sprintf("%s/api/traces?count=%d", source.url, n))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("listing traces failed with %s", resp.Status)
+	}
+
+	var page TracePage
+	err = json.NewDecoder(resp.Body).Decode(&page)
+	if err != nil {
+		return nil, err
+	}
+	return page.Traces, nil
+}
+
+func (source *remoteSource) Follow(ctx context.Context, since time.Time, traces chan<- *tracing.Trace) error {
+	query := url.Values{}
+	query.Set("from", since.Format(time.RFC3339))
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, source.url+"/api/stream?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("streaming failed with %s", resp.Status)
+	}
+
+	err = readEvents(resp.Body, func(data string) error {
+		var trc tracing.Trace
+		err := json.Unmarshal([]byte(data), &trc)
+		if err != nil {
+			return err
+		}
+
+		select {
+		case traces <- &trc:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err == nil {
+		err = fmt.Errorf("stream ended")
+	}
+	return err
+}
+
+func (source *remoteSource) ByCorrelationId(corrId string) ([]*tracing.Trace, error) {
+	query := url.Values{}
+	query.Set("correlationId", corrId)
+	query.Set("format", tracing.ExportNdjson)
+	resp, err := http.Get(source.url + "/api/export?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("listing the correlation failed with %s", resp.Status)
+	}
+
+	traces := make([]*tracing.Trace, 0)
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), max_event_size)
+	for scanner.Scan() {
+		var trc tracing.Trace
+		err = json.Unmarshal(scanner.Bytes(), &trc)
+		if err != nil {
+			return nil, err
+		}
+		traces = append(traces, &trc)
+	}
+
+	return traces, scanner.Err()
+}
@@ -1,30 +1,94 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"flag"
 	"fmt"
+	"io"
+	"log"
 	"net"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/aliostad/TraceView/tracing"
 )
 
+// subcommands, anything else starts the server
+var commands = map[string]func(args []string){
+	"import": runImport,
+	"tail":   runTail,
+	"tui":    runTui,
+	"send":   runSend,
+	"ingest": runIngest,
+	"run":    runRun,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			command(os.Args[2:])
+			return
+		}
+	}
+
+	runServer(flag.CommandLine, os.Args[1:], false)
+}
 
-	udpPortPtr := flag.Int("uport", 1969, "UDP port")
-	httpPortPtr := flag.Int("hport", 8969, "HTTP port")
-	hostPtr := flag.String("host", "0.0.0.0", "host")
-	timestampFieldNamesPtr := flag.String("tfn", "", "timestamp field names, comma separated")
-	messageFieldNamesPtr := flag.String("mfn", "", "message field names, comma separated")
-	levelFieldNamesPtr := flag.String("lfn", "", "level field names, comma separated")
-	corridFieldNamesPtr := flag.String("cfn", "", "correlation Id field names, comma separated")
-	indexableFieldNamesPtr := flag.String("ifn", "", "indexable field names, comma separated")
-	keepOriginalPayloadPtr := flag.Bool("keep-original-payload", false, "keep original payload")
+// traceview ingest [flags] -|file... starts an instance holding the lines of standard input or the files
+func runIngest(args []string) {
+	runServer(flag.NewFlagSet("ingest", flag.ExitOnError), args, true)
+}
+
+// runServer starts an instance with the flags, reading the inputs left after the flags first when ingest is set
+func runServer(flags *flag.FlagSet, args []string, ingest bool) {
+	// ingesting only needs the UI, the listeners are there when asked for
+	listenPort := 1969
+	if ingest {
+		listenPort = 0
+	}
+	udpPortPtr := flags.Int("uport", listenPort, "UDP port, 0 does not listen")
+	tcpPortPtr := flags.Int("tport", listenPort, "TCP port, for payloads one per line, 0 does not listen")
+	httpPortPtr := flags.Int("hport", 8969, "HTTP port")
+	hostPtr := flags.String("host", "0.0.0.0", "host")
+	timestampFieldNamesPtr := flags.String("tfn", "", "timestamp field names, comma separated")
+	messageFieldNamesPtr := flags.String("mfn", "", "message field names, comma separated")
+	levelFieldNamesPtr := flags.String("lfn", "", "level field names, comma separated")
+	corridFieldNamesPtr := flags.String("cfn", "", "correlation Id field names, comma separated")
+	spanIdFieldNamesPtr := flags.String("sfn", "", "span Id field names, comma separated")
+	parentSpanIdFieldNamesPtr := flags.String("pfn", "", "parent span Id field names, comma separated")
+	indexableFieldNamesPtr := flags.String("ifn", "", "indexable field names, comma separated")
+	keepOriginalPayloadPtr := flags.Bool("keep-original-payload", false, "keep original payload")
+	filtersPathPtr := flags.String("filters", "", "path to a JSON file with include/exclude filter rules")
+	redactPathPtr := flags.String("redact", "", "path to a JSON file with PII redaction rules")
+	processorsPathPtr := flags.String("processors", "", "path to a JSON file declaring the processors run between parsing and storing")
+	alertsPathPtr := flags.String("alerts", "", "path to a JSON file with alert rules")
+	allowCommandAlertsPtr := flags.Bool("allow-command-alerts", false, "let alert rules added over HTTP run commands, notify and call webhooks, not only those from -alerts")
+	dedupMaxPtr := flags.Int("dedup", 0, "only store the first N repeats of a message in every window, 0 stores all")
+	dedupWindowPtr := flags.Duration("dedup-window", time.Minute, "window of -dedup")
+	followPtr := flags.String("follow", "", "globs of log files to follow like tail -F, comma separated")
+	followFirstLinePtr := flags.String("follow-first-line", "", "pattern of the first line of a record in followed files, lines not matching it belong to the record before")
+	followOffsetsPtr := flags.String("follow-offsets", "traceview-offsets.json", "file keeping how far followed files were read across restarts")
+	tuiPtr := flags.Bool("tui", false, "browse the traces in a terminal UI instead of the console")
+	dedupByPtr := flags.String("dedup-by", tracing.CollapsePattern, "what makes repeats of -dedup: message or pattern, along with the level and correlation id")
 
-	flag.Parse()
+	flags.Parse(args)
+	if ingest && flags.NArg() == 0 {
+		fmt.Println("Usage: traceview ingest [flags] -|file...")
+		flags.PrintDefaults()
+		os.Exit(2)
+	}
+
+	if *dedupByPtr != tracing.CollapseMessage && *dedupByPtr != tracing.CollapsePattern {
+		handleErrorNot(fmt.Errorf("unknown -dedup-by %q", *dedupByPtr))
+	}
 
-	fmt.Println("This is the UDP port: ", *udpPortPtr)
+	if *udpPortPtr != 0 {
+		fmt.Println("This is the UDP port: ", *udpPortPtr)
+	}
 	fmt.Println("This is the HTTP port: ", *httpPortPtr)
 	fmt.Println("This is host", *hostPtr)
 
@@ -33,6 +97,8 @@ func main() {
 		MessageFieldNames:       splitNames(messageFieldNamesPtr),
 		LevelFieldNames:         splitNames(levelFieldNamesPtr),
 		CorrelationIdFieldNames: splitNames(corridFieldNamesPtr),
+		SpanIdFieldNames:        splitNames(spanIdFieldNamesPtr),
+		ParentSpanIdFieldNames:  splitNames(parentSpanIdFieldNamesPtr),
 		IndexableFieldNames:     splitNames(indexableFieldNamesPtr),
 		KeepOriginalPayload:     *keepOriginalPayloadPtr,
 	}
@@ -40,15 +106,160 @@ func main() {
 	store, err := tracing.NewInMemoryStore(&config)
 	handleErrorNot(err)
 	parser := tracing.NewPayloadParserWithConfig(&config)
+	filters := tracing.NewFilterRules()
+	if *filtersPathPtr != "" {
+		filters, err = tracing.LoadFilterRules(*filtersPathPtr)
+		handleErrorNot(err)
+	}
 
-	dispatch := make(chan string, 200)
+	redactor, err := tracing.NewRedactor(nil)
+	handleErrorNot(err)
+	if *redactPathPtr != "" {
+		redactor, err = tracing.LoadRedactor(*redactPathPtr)
+		handleErrorNot(err)
+	}
+
+	processors := make([]tracing.Processor, 0)
+	if *processorsPathPtr != "" {
+		processors, err = tracing.LoadProcessors(*processorsPathPtr)
+		handleErrorNot(err)
+	}
+
+	alerter := tracing.NewAlerter()
+	if *alertsPathPtr != "" {
+		alerter, err = tracing.LoadAlertRules(*alertsPathPtr)
+		handleErrorNot(err)
+	}
+
+	highlighter, err := tracing.NewHighlighter(store)
+	handleErrorNot(err)
+	sessions, err := tracing.NewSessions(store)
+	handleErrorNot(err)
+
+	// redaction runs after the configured processors so what they extract is redacted too, and before anything else sees PII
+	stages := tracing.RedactedStages(redactor, processors)
+	fields := tracing.NewFieldCatalog()
+	// patterns are assigned ahead of the filters so traces can be filtered by pattern,
+	// and counted after them and dedup so only stored traces are
+	patterns := tracing.NewPatternMiner()
+	anomalies := tracing.NewAnomalyDetector(patterns)
+	broadcaster := tracing.NewBroadcaster()
+	// after the filters, so traces excluded anyway do not use up the windows
+	dedup := tracing.NewDeduplicator(*dedupMaxPtr, *dedupWindowPtr, *dedupByPtr)
+	pipeline := tracing.NewPipeline(append(append([]tracing.Processor{}, stages...),
+		patterns, filters, dedup, patterns.Counter(), highlighter, sessions, fields, anomalies, alerter, broadcaster)...)
+	// imported history is not live: it must not fire alerts, skew the learned rates, count against
+	// the dedup windows or reach the streams
+	imports := tracing.NewPipeline(append(append([]tracing.Processor{}, stages...),
+		patterns, filters, patterns.Counter(), highlighter, sessions, fields)...)
+	ingester := tracing.NewIngesterWithImports(store, pipeline, imports)
+	// built from the traces as they pass, so forgotten when all of them are cleared,
+	// the learned rates along with the patterns they are kept for
+	derived := []tracing.Resettable{fields, patterns, anomalies, dedup}
+
+	dispatch := make(chan datagram, 200)
 	defer close(dispatch)
-	go listenUdp(*udpPortPtr, *hostPtr, dispatch)
-	go readFrom(store, parser, dispatch)
-	api := NewTraceApi(*httpPortPtr, *hostPtr, &config, store)
+	if *udpPortPtr != 0 {
+		go listenUdp(*udpPortPtr, *hostPtr, dispatch)
+	}
+	if *tcpPortPtr != 0 {
+		go listenTcp(*tcpPortPtr, *hostPtr, dispatch)
+	}
+	if *followPtr != "" {
+		follower, err := tracing.NewFileFollower(&tracing.FollowConfig{
+			Globs:       splitNames(followPtr),
+			FirstLine:   *followFirstLinePtr,
+			OffsetsPath: *followOffsetsPtr,
+			OnError: func(path string, err error) {
+				log.Println("Could not follow", path+":", err.Error())
+			},
+		}, parser, ingester.Ingest)
+		handleErrorNot(err)
+		follower.Start(follow_interval)
+		defer follower.Stop()
+	}
+
+	go readFrom(parser, ingester, dispatch)
+	api := NewTraceApi(*httpPortPtr, *hostPtr, &config, store, ingester, filters, highlighter, sessions, fields, patterns, alerter, *allowCommandAlertsPtr, anomalies, broadcaster, derived)
 	api.Start()
 	defer api.Stop(context.Background())
-	_, _ = fmt.Scanln() // wait for user input
+	if ingest {
+		for _, path := range flags.Args() {
+			count, err := readLines(path, dispatch)
+			handleErrorNot(err)
+			fmt.Printf("%s: read %d lines\n", path, count)
+		}
+
+		if !*tuiPtr {
+			// the console would read standard input, which can be what was ingested
+			fmt.Printf("Browse at http://localhost:%d, Ctrl-C to stop\n", *httpPortPtr)
+			interrupted := make(chan os.Signal, 1)
+			signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+			<-interrupted
+			return
+		}
+	}
+
+	if *tuiPtr {
+		// anything logged would scribble over the screen
+		log.SetOutput(io.Discard)
+		err = newTui(&localSource{store: store, broadcaster: broadcaster}).run()
+		log.SetOutput(os.Stderr)
+		handleErrorNot(err)
+		return
+	}
+
+	runConsole(store, ingester, derived)
+}
+
+// reads commands from the user until an empty line, quit or the end of input
+func runConsole(store tracing.TraceStore, ingester *tracing.Ingester, derived []tracing.Resettable) {
+	fmt.Println("Commands: pause [buffer|drop], resume, clear, status, quit")
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			return
+		}
+
+		var err error
+		switch fields[0] {
+		case "pause":
+			mode := tracing.PauseBuffer
+			if len(fields) > 1 {
+				mode = fields[1]
+			}
+			err = ingester.Pause(mode)
+		case "resume":
+			err = ingester.Resume()
+		case "clear":
+			err = store.Clear()
+			if err == nil {
+				resetAll(derived)
+			}
+		case "status":
+		case "quit", "exit":
+			return
+		default:
+			fmt.Println("Unknown command: ", fields[0])
+			continue
+		}
+
+		if err != nil {
+			fmt.Println("Could not", fields[0]+":", err.Error())
+			continue
+		}
+
+		status := ingester.Status()
+		fmt.Printf("paused: %v %s, received: %d, buffered: %d, dropped: %d\n",
+			status.Paused, status.Mode, status.Received, status.Buffered, status.Dropped)
+	}
+}
+
+func resetAll(derived []tracing.Resettable) {
+	for _, state := range derived {
+		state.Reset()
+	}
 }
 
 // comma
@@ -59,23 +270,65 @@ func splitNames(cfg *string) []string {
 	return strings.Split(*cfg, ",")
 }
 
-func readFrom(store tracing.TraceStore,
-	parser *tracing.PayloadParser,
-	dispatch <-chan string) {
+// how often followed files are checked for what was written
+const follow_interval = 250 * time.Millisecond
+
+// payloads read line by line can be up to this long
+const max_line_size = 1024 * 1024
+
+// a payload as received along with where it came from
+type datagram struct {
+	payload string
+	source  string
+}
+
+func readFrom(parser *tracing.PayloadParser,
+	ingester *tracing.Ingester,
+	dispatch <-chan datagram) {
 	for dispatchData := range dispatch {
-		trc, err := parser.Parse(dispatchData)
+		trc, err := parser.Parse(dispatchData.payload)
 		if err != nil {
-			fmt.Println("Could not parse: ", dispatchData, err.Error())
+			log.Println("Could not parse: ", dispatchData.payload, err.Error())
 		} else {
-			err = store.Store(trc, dispatchData)
+			trc.Source = dispatchData.source
+			err = ingester.Ingest(&tracing.Entry{Trace: trc, Payload: dispatchData.payload})
 			if err != nil {
-				fmt.Println("Could not store: ", trc, err.Error())
+				log.Println("Could not store: ", trc, err.Error())
 			}
 		}
 	}
 }
 
-func listenUdp(port int, host string, dispatch chan<- string) {
+// reads the lines of the file, or standard input for -, as payloads
+func readLines(path string, dispatch chan<- datagram) (int, error) {
+	source := "stdin"
+	file := os.Stdin
+	if path != "-" {
+		var err error
+		file, err = os.Open(path)
+		if err != nil {
+			return 0, err
+		}
+		defer file.Close()
+		source = "file:" + path
+	}
+
+	count := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), max_line_size)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		dispatch <- datagram{payload: line, source: source}
+		count++
+	}
+
+	return count, scanner.Err()
+}
+
+func listenUdp(port int, host string, dispatch chan<- datagram) {
 
 	conn, err := net.ListenUDP("udp", &net.UDPAddr{
 		Port: port,
@@ -85,15 +338,52 @@ func listenUdp(port int, host string, dispatch chan<- string) {
 	handleErrorNot(err)
 
 	defer conn.Close()
-	fmt.Printf("UDP listening at %s\n", conn.LocalAddr().String())
+	log.Printf("UDP listening at %s\n", conn.LocalAddr().String())
 
 	buffer := make([]byte, 64*1024)
 	for {
-		len, _, err := conn.ReadFromUDP(buffer[:])
+		len, addr, err := conn.ReadFromUDP(buffer[:])
 		handleErrorNot(err)
 
 		data := strings.TrimSpace(string(buffer[:len]))
-		dispatch <- data
+		dispatch <- datagram{payload: data, source: addr.String()}
+	}
+}
+
+// every connection sends payloads one per line
+func listenTcp(port int, host string, dispatch chan<- datagram) {
+
+	listener, err := net.ListenTCP("tcp", &net.TCPAddr{
+		Port: port,
+		IP:   net.ParseIP(host),
+	})
+
+	handleErrorNot(err)
+
+	defer listener.Close()
+	log.Printf("TCP listening at %s\n", listener.Addr().String())
+
+	for {
+		conn, err := listener.Accept()
+		handleErrorNot(err)
+		go readConnection(conn, dispatch)
+	}
+}
+
+func readConnection(conn net.Conn, dispatch chan<- datagram) {
+	defer conn.Close()
+	source := conn.RemoteAddr().String()
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), max_line_size)
+	for scanner.Scan() {
+		data := strings.TrimSpace(scanner.Text())
+		if data != "" {
+			dispatch <- datagram{payload: data, source: source}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Println("Could not read from", source+":", err.Error())
 	}
 }
 
@@ -0,0 +1,218 @@
+package tracing
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	auto_buckets = 60
+	max_buckets  = 1000
+	default_top  = 10
+)
+
+var ErrTooManyBuckets = errors.New("too many buckets, use a larger bucket or a shorter range")
+
+// sizes picked from when no bucket is asked for, all divide a day so buckets line up with the clock
+var bucketSizes = []time.Duration{
+	time.Second,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+	time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+	30 * time.Minute,
+	time.Hour,
+	3 * time.Hour,
+	6 * time.Hour,
+	12 * time.Hour,
+	24 * time.Hour,
+}
+
+type AggregateQuery struct {
+	From     *time.Time
+	To       *time.Time
+	Match    *Match
+	Bucket   time.Duration // zero picks a size giving about 60 buckets
+	GroupBy  string        // a trace field such as Level, Properties.name or a bare property name
+	TopField string        // same as GroupBy, the field to count distinct values of
+	TopN     int
+}
+
+type CountBucket struct {
+	Start  time.Time
+	Total  int
+	Groups map[string]int
+}
+
+type ValueCount struct {
+	Value string
+	Count int
+}
+
+type Aggregation struct {
+	Start    time.Time
+	End      time.Time
+	BucketMs float64
+	Buckets  []*CountBucket
+	Top      []*ValueCount
+}
+
+// timeBuckets maps timestamps of a range onto fixed size buckets
+type timeBuckets struct {
+	from  time.Time // where the range starts, start is its bucket's start
+	start time.Time
+	end   time.Time
+	size  time.Duration
+	count int
+}
+
+// resolves the range of the query: it starts at from or the first matching trace and ends at to or now.
+// Returns nil when there is nothing to bucket.
+func newTimeBuckets(store TraceStore, from, to *time.Time, match *Match, size time.Duration) (*timeBuckets, error) {
+	end := time.Now().UTC()
+	if to != nil {
+		end = *to
+	}
+
+	var start time.Time
+	if from != nil {
+		start = *from
+	} else {
+		page, err := store.ListPage(1, nil, to, match, nil, false)
+		if err != nil {
+			return nil, err
+		}
+		if len(page.Traces) == 0 {
+			return nil, nil
+		}
+		start = page.Traces[0].Timestamp
+	}
+
+	if end.Before(start) {
+		return nil, nil
+	}
+
+	if size <= 0 {
+		// whole days for ranges too long for the largest size
+		day := bucketSizes[len(bucketSizes)-1]
+		size = (end.Sub(start)/auto_buckets/day + 1) * day
+		for _, candidate := range bucketSizes {
+			if end.Sub(start)/candidate < auto_buckets {
+				size = candidate
+				break
+			}
+		}
+	}
+
+	bucketStart := start.Truncate(size)
+	count := int(end.Sub(bucketStart)/size) + 1
+	if count > max_buckets {
+		return nil, ErrTooManyBuckets
+	}
+
+	return &timeBuckets{from: start, start: bucketStart, end: end, size: size, count: count}, nil
+}
+
+func (buckets *timeBuckets) index(ts time.Time) int {
+	i := int(ts.Sub(buckets.start) / buckets.size)
+	if i < 0 || i >= buckets.count {
+		return -1
+	}
+	return i
+}
+
+func (buckets *timeBuckets) bucketStart(i int) time.Time {
+	return buckets.start.Add(time.Duration(i) * buckets.size)
+}
+
+// Aggregate counts the traces matching the query in time buckets, grouped by a field,
+// along with the most frequent values of a field across the whole range
+func Aggregate(store TraceStore, query *AggregateQuery) (*Aggregation, error) {
+	aggregation := &Aggregation{
+		Buckets: make([]*CountBucket, 0),
+		Top:     make([]*ValueCount, 0),
+	}
+
+	buckets, err := newTimeBuckets(store, query.From, query.To, query.Match, query.Bucket)
+	if err != nil || buckets == nil {
+		return aggregation, err
+	}
+
+	aggregation.Start = buckets.start
+	aggregation.End = buckets.end
+	aggregation.BucketMs = toMs(buckets.size)
+	for i := 0; i < buckets.count; i++ {
+		aggregation.Buckets = append(aggregation.Buckets, &CountBucket{
+			Start:  buckets.bucketStart(i),
+			Groups: make(map[string]int),
+		})
+	}
+
+	topCounts := make(map[string]int)
+	err = store.Walk(&buckets.from, &buckets.end, query.Match, func(trc *Trace) bool {
+		i := buckets.index(trc.Timestamp)
+		if i < 0 {
+			return true
+		}
+
+		bucket := aggregation.Buckets[i]
+		bucket.Total++
+		if query.GroupBy != "" {
+			bucket.Groups[FieldValue(trc, query.GroupBy)]++
+		}
+		if query.TopField != "" {
+			topCounts[FieldValue(trc, query.TopField)]++
+		}
+		return true
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	aggregation.Top = topValues(topCounts, query.TopN)
+	return aggregation, nil
+}
+
+func topValues(counts map[string]int, n int) []*ValueCount {
+	if n <= 0 {
+		n = default_top
+	}
+
+	values := make([]*ValueCount, 0, len(counts))
+	for value, count := range counts {
+		values = append(values, &ValueCount{Value: value, Count: count})
+	}
+
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return values[i].Value < values[j].Value
+	})
+
+	if len(values) > n {
+		values = values[:n]
+	}
+	return values
+}
+
+// FieldValue returns a trace field by the name used for CSV columns, a name that is not
+// a trace field is taken as a property
+func FieldValue(trc *Trace, name string) string {
+	if field, ok := csvFields[name]; ok {
+		return field(trc)
+	}
+	if strings.HasPrefix(name, "Metrics.") {
+		if value, ok := trc.Metrics[strings.TrimPrefix(name, "Metrics.")]; ok {
+			return strconv.FormatFloat(value, 'f', -1, 64)
+		}
+		return ""
+	}
+	return trc.Properties[strings.TrimPrefix(name, "Properties.")]
+}
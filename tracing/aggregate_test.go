@@ -0,0 +1,76 @@
+package tracing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var aggregateStart = time.Date(2022, 4, 4, 10, 0, 0, 0, time.UTC)
+
+func getAggregateStore(t *testing.T) *InMemoryStore {
+	store, err := NewInMemoryStore(EmptyConfig())
+	assert.Nil(t, err)
+	for i := 0; i < 30; i++ {
+		level := "info"
+		if i%3 == 0 {
+			level = "error"
+		}
+		trc := NewTrace(aggregateStart.Add(time.Duration(i)*10*time.Second), "hello", "12345", level)
+		trc.Properties["user"] = []string{"jo", "al", "jo", "mo", "jo"}[i%5]
+		trc.Metrics["took"] = float64(i)
+		_ = store.Store(trc, "")
+	}
+	return store
+}
+
+func Test_aggregate_by_level(t *testing.T) {
+	store := getAggregateStore(t)
+	to := aggregateStart.Add(5 * time.Minute)
+	aggregation, err := Aggregate(store, &AggregateQuery{
+		From:    &aggregateStart,
+		To:      &to,
+		Bucket:  time.Minute,
+		GroupBy: "Level",
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 6, len(aggregation.Buckets))
+	assert.Equal(t, float64(60000), aggregation.BucketMs)
+	assert.Equal(t, 6, aggregation.Buckets[0].Total)
+	assert.Equal(t, 2, aggregation.Buckets[0].Groups["error"])
+	assert.Equal(t, 4, aggregation.Buckets[0].Groups["info"])
+	assert.Equal(t, 0, aggregation.Buckets[5].Total)
+}
+
+func Test_aggregate_top_values_under_a_filter(t *testing.T) {
+	store := getAggregateStore(t)
+	match := &Match{Levels: []string{"info"}}
+	assert.Nil(t, match.Compile())
+	to := aggregateStart.Add(time.Hour)
+	aggregation, err := Aggregate(store, &AggregateQuery{
+		To:       &to,
+		Match:    match,
+		TopField: "user",
+		TopN:     2,
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, aggregateStart.Add(10*time.Second).Truncate(time.Minute), aggregation.Start)
+	assert.Equal(t, []*ValueCount{{Value: "jo", Count: 12}, {Value: "al", Count: 4}}, aggregation.Top)
+}
+
+func Test_aggregate_refuses_too_many_buckets(t *testing.T) {
+	store := getAggregateStore(t)
+	to := aggregateStart.Add(24 * time.Hour)
+	_, err := Aggregate(store, &AggregateQuery{From: &aggregateStart, To: &to, Bucket: time.Second})
+	assert.Equal(t, ErrTooManyBuckets, err)
+}
+
+func Test_aggregate_empty_store(t *testing.T) {
+	store, _ := NewInMemoryStore(EmptyConfig())
+	aggregation, err := Aggregate(store, &AggregateQuery{})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(aggregation.Buckets))
+}
@@ -0,0 +1,339 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	AlertWebhook = "webhook" // POSTs the alert as JSON to Url
+	AlertCommand = "command" // runs Command with Args, the alert as JSON on its standard input
+	AlertBell    = "bell"    // rings the terminal bell
+	AlertNotify  = "notify"  // shows a desktop notification with notify-send
+
+	max_alert_history  = 1000
+	max_alert_traces   = 10               // trace ids kept on an alert
+	alert_action_limit = 10 * time.Second // how long an action can take
+)
+
+var ErrRemoteAlertAction = errors.New("webhook, command and notify actions can only come from the alert rules file unless -allow-command-alerts is set")
+
+type AlertAction struct {
+	Type    string
+	Url     string
+	Command string
+	Args    []string
+}
+
+// AlertRule fires once Threshold traces satisfying Match arrive within Window, e.g. 5 in 1m.
+// Counting starts over after it fires.
+type AlertRule struct {
+	Id        string
+	Name      string
+	Match     Match
+	Threshold int
+	Window    string // a duration such as 30s, defaults to a minute
+	Actions   []*AlertAction
+
+	window time.Duration
+	recent []*Trace
+}
+
+type Alert struct {
+	Id       string
+	RuleId   string
+	RuleName string
+	Fired    time.Time
+	Count    int
+	Message  string   // of the trace which made the rule fire
+	TraceIds []string // the latest of the traces counted
+	Errors   []string // actions which failed
+}
+
+// Alerter evaluates the alert rules against incoming traces and keeps the history of alerts
+type Alerter struct {
+	lock    sync.Mutex
+	rules   []*AlertRule
+	history []*Alert
+	run     func(action *AlertAction, alert *Alert) error
+	pending sync.WaitGroup
+}
+
+func NewAlerter() *Alerter {
+	return &Alerter{
+		rules:   make([]*AlertRule, 0),
+		history: make([]*Alert, 0),
+		run:     runAlertAction,
+	}
+}
+
+// LoadAlertRules reads a JSON array of rules from a file
+func LoadAlertRules(path string) (*Alerter, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []*AlertRule
+	err = json.Unmarshal(data, &rules)
+	if err != nil {
+		return nil, err
+	}
+
+	alerter := NewAlerter()
+	for _, rule := range rules {
+		_, err = alerter.Add(rule)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return alerter, nil
+}
+
+// Add validates the rule and assigns a new id to it
+func (alerter *Alerter) Add(rule *AlertRule) (*AlertRule, error) {
+	err := validateAlertRule(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	rule.Id = uuid.New().String()
+	alerter.lock.Lock()
+	defer alerter.lock.Unlock()
+	alerter.rules = append(alerter.rules, rule)
+	return rule, nil
+}
+
+// Update replaces the rule with the same id and returns false if there is none
+func (alerter *Alerter) Update(rule *AlertRule) (bool, error) {
+	err := validateAlertRule(rule)
+	if err != nil {
+		return false, err
+	}
+
+	alerter.lock.Lock()
+	defer alerter.lock.Unlock()
+	for i, existing := range alerter.rules {
+		if existing.Id == rule.Id {
+			alerter.rules[i] = rule
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Remove returns false if no rule with the id exists
+func (alerter *Alerter) Remove(id string) bool {
+	alerter.lock.Lock()
+	defer alerter.lock.Unlock()
+	for i, existing := range alerter.rules {
+		if existing.Id == id {
+			alerter.rules = append(alerter.rules[:i], alerter.rules[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+func (alerter *Alerter) List() []*AlertRule {
+	alerter.lock.Lock()
+	defer alerter.lock.Unlock()
+	return append([]*AlertRule{}, alerter.rules...)
+}
+
+// History returns the alerts fired, latest first
+func (alerter *Alerter) History() []*Alert {
+	alerter.lock.Lock()
+	defer alerter.lock.Unlock()
+	history := make([]*Alert, 0, len(alerter.history))
+	for i := len(alerter.history) - 1; i >= 0; i-- {
+		alert := *alerter.history[i]
+		alert.Errors = append([]string{}, alert.Errors...)
+		history = append(history, &alert)
+	}
+	return history
+}
+
+func validateAlertRule(rule *AlertRule) error {
+	if rule.Threshold < 1 {
+		rule.Threshold = 1
+	}
+
+	rule.window = time.Minute
+	if rule.Window != "" {
+		window, err := time.ParseDuration(rule.Window)
+		if err != nil {
+			return err
+		}
+		if window <= 0 {
+			return errors.New("alert window must be positive")
+		}
+		rule.window = window
+	}
+
+	if len(rule.Actions) == 0 {
+		return errors.New("alert rule needs at least one action")
+	}
+
+	for _, action := range rule.Actions {
+		switch action.Type {
+		case AlertWebhook:
+			if action.Url == "" {
+				return errors.New("webhook action needs a Url")
+			}
+		case AlertCommand:
+			if action.Command == "" {
+				return errors.New("command action needs a Command")
+			}
+		case AlertBell, AlertNotify:
+		default:
+			return fmt.Errorf("unknown alert action %q", action.Type)
+		}
+	}
+
+	rule.recent = nil
+	return rule.Match.Compile()
+}
+
+// CheckRemoteAlertRule rejects rules coming over the API whose actions run commands or reach other hosts
+func CheckRemoteAlertRule(rule *AlertRule) error {
+	for _, action := range rule.Actions {
+		switch action.Type {
+		case AlertWebhook, AlertCommand, AlertNotify:
+			return ErrRemoteAlertAction
+		}
+	}
+
+	return nil
+}
+
+func (alerter *Alerter) Process(entry *Entry) ([]*Entry, error) {
+	alerter.Evaluate(entry.Trace)
+	return []*Entry{entry}, nil
+}
+
+// Evaluate counts the trace against every rule it matches and fires those reaching their threshold
+func (alerter *Alerter) Evaluate(trc *Trace) {
+	alerter.lock.Lock()
+	defer alerter.lock.Unlock()
+	for _, rule := range alerter.rules {
+		if !rule.Match.IsMatch(trc) {
+			continue
+		}
+
+		recent := rule.recent[:0]
+		for _, previous := range rule.recent {
+			if trc.Timestamp.Sub(previous.Timestamp) < rule.window {
+				recent = append(recent, previous)
+			}
+		}
+		rule.recent = append(recent, trc)
+		if len(rule.recent) < rule.Threshold {
+			continue
+		}
+
+		alert := &Alert{
+			Id:       uuid.New().String(),
+			RuleId:   rule.Id,
+			RuleName: rule.Name,
+			Fired:    time.Now().UTC(),
+			Count:    len(rule.recent),
+			Message:  trc.Message,
+			TraceIds: make([]string, 0),
+			Errors:   make([]string, 0),
+		}
+
+		start := 0
+		if len(rule.recent) > max_alert_traces {
+			start = len(rule.recent) - max_alert_traces
+		}
+		for _, counted := range rule.recent[start:] {
+			alert.TraceIds = append(alert.TraceIds, counted.TraceId)
+		}
+
+		rule.recent = nil
+		alerter.history = append(alerter.history, alert)
+		if len(alerter.history) > max_alert_history {
+			alerter.history = alerter.history[len(alerter.history)-max_alert_history:]
+		}
+
+		// actions can be slow so they run off the ingest path, on a copy as failures are added to the alert
+		snapshot := *alert
+		for _, action := range rule.Actions {
+			alerter.pending.Add(1)
+			go alerter.fire(action, &snapshot, alert)
+		}
+	}
+}
+
+func (alerter *Alerter) fire(action *AlertAction, snapshot *Alert, alert *Alert) {
+	defer alerter.pending.Done()
+	err := alerter.run(action, snapshot)
+	if err != nil {
+		alerter.lock.Lock()
+		defer alerter.lock.Unlock()
+		alert.Errors = append(alert.Errors, action.Type+": "+err.Error())
+	}
+}
+
+// Wait returns once the actions of the alerts fired so far have run
+func (alerter *Alerter) Wait() {
+	alerter.pending.Wait()
+}
+
+func runAlertAction(action *AlertAction, alert *Alert) error {
+	ctx, cancel := context.WithTimeout(context.Background(), alert_action_limit)
+	defer cancel()
+	switch action.Type {
+	case AlertWebhook:
+		data, err := json.Marshal(alert)
+		if err != nil {
+			return err
+		}
+
+		request, err := http.NewRequestWithContext(ctx, http.MethodPost, action.Url, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+
+		request.Header.Set("Content-Type", "application/json")
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			return err
+		}
+		defer response.Body.Close()
+		if response.StatusCode >= 300 {
+			return fmt.Errorf("webhook returned %d", response.StatusCode)
+		}
+		return nil
+	case AlertCommand:
+		data, err := json.Marshal(alert)
+		if err != nil {
+			return err
+		}
+
+		cmd := exec.CommandContext(ctx, action.Command, action.Args...)
+		cmd.Stdin = bytes.NewReader(data)
+		return cmd.Run()
+	case AlertBell:
+		_, err := os.Stdout.WriteString("\a")
+		return err
+	case AlertNotify:
+		return exec.CommandContext(ctx, "notify-send", "TraceView: "+alert.RuleName, alert.Message).Run()
+	}
+
+	return nil
+}
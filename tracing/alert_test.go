@@ -0,0 +1,120 @@
+package tracing
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getAlertRule(threshold int, window string) *AlertRule {
+	return &AlertRule{
+		Name:      "errors",
+		Match:     Match{Levels: []string{"error"}},
+		Threshold: threshold,
+		Window:    window,
+		Actions:   []*AlertAction{{Type: AlertBell}},
+	}
+}
+
+func Test_alert_fires_at_threshold_within_window(t *testing.T) {
+	alerter := NewAlerter()
+	fired := 0
+	var lock sync.Mutex
+	alerter.run = func(action *AlertAction, alert *Alert) error {
+		lock.Lock()
+		defer lock.Unlock()
+		fired++
+		return nil
+	}
+
+	_, err := alerter.Add(getAlertRule(3, "1m"))
+	assert.Nil(t, err)
+
+	start := time.Date(2022, 4, 4, 10, 0, 0, 0, time.UTC)
+	alerter.Evaluate(NewTrace(start, "boom", "1", "error"))
+	alerter.Evaluate(NewTrace(start.Add(time.Second), "fine", "1", "info"))
+	alerter.Evaluate(NewTrace(start.Add(2*time.Minute), "boom", "1", "error"))
+	alerter.Evaluate(NewTrace(start.Add(2*time.Minute+time.Second), "boom", "1", "error"))
+	assert.Equal(t, 0, len(alerter.History()))
+
+	alerter.Evaluate(NewTrace(start.Add(2*time.Minute+2*time.Second), "boom again", "1", "error"))
+	alerter.Wait()
+	history := alerter.History()
+	assert.Equal(t, 1, len(history))
+	assert.Equal(t, 3, history[0].Count)
+	assert.Equal(t, "boom again", history[0].Message)
+	assert.Equal(t, 3, len(history[0].TraceIds))
+	assert.Equal(t, 1, fired)
+}
+
+func Test_alert_records_failed_actions(t *testing.T) {
+	alerter := NewAlerter()
+	alerter.run = func(action *AlertAction, alert *Alert) error {
+		return errors.New("no display")
+	}
+
+	_, err := alerter.Add(getAlertRule(1, ""))
+	assert.Nil(t, err)
+	alerter.Evaluate(NewTrace(time.Now(), "boom", "1", "error"))
+	alerter.Wait()
+	assert.Equal(t, []string{"bell: no display"}, alerter.History()[0].Errors)
+}
+
+func Test_alert_webhook(t *testing.T) {
+	received := make(chan *Alert, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert Alert
+		_ = json.NewDecoder(r.Body).Decode(&alert)
+		received <- &alert
+	}))
+	defer server.Close()
+
+	alerter := NewAlerter()
+	rule := getAlertRule(1, "")
+	rule.Actions = []*AlertAction{{Type: AlertWebhook, Url: server.URL}}
+	_, err := alerter.Add(rule)
+	assert.Nil(t, err)
+
+	alerter.Evaluate(NewTrace(time.Now(), "boom", "1", "error"))
+	alerter.Wait()
+	alert := <-received
+	assert.Equal(t, "errors", alert.RuleName)
+	assert.Equal(t, 0, len(alerter.History()[0].Errors))
+}
+
+func Test_alert_rule_validation(t *testing.T) {
+	alerter := NewAlerter()
+	rule := getAlertRule(1, "soon")
+	_, err := alerter.Add(rule)
+	assert.NotNil(t, err)
+
+	rule = getAlertRule(1, "")
+	rule.Actions = []*AlertAction{{Type: "pager"}}
+	_, err = alerter.Add(rule)
+	assert.NotNil(t, err)
+
+	rule = getAlertRule(1, "")
+	rule.Actions = nil
+	_, err = alerter.Add(rule)
+	assert.NotNil(t, err)
+}
+
+func Test_alert_remote_rules_cannot_run_commands(t *testing.T) {
+	rule := getAlertRule(1, "")
+	assert.Nil(t, CheckRemoteAlertRule(rule))
+
+	for _, action := range []*AlertAction{
+		{Type: AlertCommand, Command: "rm"},
+		{Type: AlertNotify},
+		{Type: AlertWebhook, Url: "http://localhost"},
+	} {
+		rule.Actions = []*AlertAction{{Type: AlertBell}, action}
+		assert.Equal(t, ErrRemoteAlertAction, CheckRemoteAlertRule(rule))
+	}
+}
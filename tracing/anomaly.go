@@ -0,0 +1,187 @@
+package tracing
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	AnomalyBurst   = "burst"   // far more traces than usual in a minute
+	AnomalyStopped = "stopped" // none in a minute where there usually are some
+
+	anomaly_learning_minutes = 5    // minutes of history before a rate is judged
+	anomaly_alpha            = 0.2  // weight of the latest minute in the learned rate
+	anomaly_score            = 3.0  // standard deviations from the learned rate to count as an anomaly
+	anomaly_min_burst        = 5    // traces above the learned rate for a burst, so a quiet rate going 0 to 1 is not one
+	anomaly_min_stopped_rate = 3.0  // learned traces per minute below which stopping is not an anomaly
+	anomaly_forget_rate      = 0.01 // learned rate below which a rate is forgotten
+	max_anomaly_gap_minutes  = 60   // quiet minutes closed one by one, longer gaps are skipped
+	max_anomalies            = 1000
+)
+
+type Anomaly struct {
+	Kind      string
+	Level     string // set for the rate of a level
+	PatternId string // set for the rate of a pattern
+	Template  string
+	Minute    time.Time
+	Count     int
+	Expected  float64 // learned traces per minute
+	Score     float64 // standard deviations from the expected
+}
+
+type rateStats struct {
+	mean     float64
+	variance float64
+	minutes  int
+	stopped  bool
+}
+
+type rateKey struct {
+	level     string
+	patternId string
+}
+
+// AnomalyDetector learns the per-minute rate of every level and message pattern as traces arrive
+// and flags the minutes deviating from it
+type AnomalyDetector struct {
+	lock      sync.Mutex
+	patterns  *PatternMiner
+	now       func() time.Time
+	minute    time.Time
+	counts    map[rateKey]int
+	stats     map[rateKey]*rateStats
+	anomalies []*Anomaly
+}
+
+// NewAnomalyDetector takes the pattern miner to name the patterns, which can be nil
+func NewAnomalyDetector(patterns *PatternMiner) *AnomalyDetector {
+	return &AnomalyDetector{
+		patterns:  patterns,
+		now:       time.Now,
+		counts:    make(map[rateKey]int),
+		stats:     make(map[rateKey]*rateStats),
+		anomalies: make([]*Anomaly, 0),
+	}
+}
+
+func (detector *AnomalyDetector) Process(entry *Entry) ([]*Entry, error) {
+	detector.Observe(entry.Trace)
+	return []*Entry{entry}, nil
+}
+
+// Observe counts the trace in the current minute of its level and pattern
+func (detector *AnomalyDetector) Observe(trc *Trace) {
+	detector.lock.Lock()
+	defer detector.lock.Unlock()
+	detector.advance()
+	detector.counts[rateKey{level: trc.Level}]++
+	if trc.PatternId != "" {
+		detector.counts[rateKey{patternId: trc.PatternId}]++
+	}
+}
+
+// Anomalies returns the anomalies of the minutes gone by, latest first
+func (detector *AnomalyDetector) Anomalies() []*Anomaly {
+	detector.lock.Lock()
+	defer detector.lock.Unlock()
+	detector.advance()
+	anomalies := make([]*Anomaly, 0, len(detector.anomalies))
+	for i := len(detector.anomalies) - 1; i >= 0; i-- {
+		anomalies = append(anomalies, detector.anomalies[i])
+	}
+	return anomalies
+}
+
+// Reset forgets the learned rates and the anomalies, e.g. before rerunning a scenario
+func (detector *AnomalyDetector) Reset() {
+	detector.lock.Lock()
+	defer detector.lock.Unlock()
+	detector.minute = time.Time{}
+	detector.counts = make(map[rateKey]int)
+	detector.stats = make(map[rateKey]*rateStats)
+	detector.anomalies = make([]*Anomaly, 0)
+}
+
+// closes the minutes up to the current one
+func (detector *AnomalyDetector) advance() {
+	current := detector.now().UTC().Truncate(time.Minute)
+	if detector.minute.IsZero() {
+		detector.minute = current
+		return
+	}
+
+	for closed := 0; detector.minute.Before(current); closed++ {
+		if closed == max_anomaly_gap_minutes {
+			detector.minute = current
+			break
+		}
+		detector.closeMinute()
+		detector.minute = detector.minute.Add(time.Minute)
+	}
+}
+
+func (detector *AnomalyDetector) closeMinute() {
+	for key := range detector.counts {
+		if _, ok := detector.stats[key]; !ok {
+			detector.stats[key] = &rateStats{}
+		}
+	}
+
+	for key, stats := range detector.stats {
+		count := detector.counts[key]
+		if stats.minutes >= anomaly_learning_minutes {
+			detector.judge(key, stats, count)
+		}
+
+		diff := float64(count) - stats.mean
+		stats.mean += anomaly_alpha * diff
+		stats.variance = (1 - anomaly_alpha) * (stats.variance + anomaly_alpha*diff*diff)
+		stats.minutes++
+		if count == 0 && stats.mean < anomaly_forget_rate {
+			delete(detector.stats, key)
+		}
+	}
+
+	detector.counts = make(map[rateKey]int)
+}
+
+func (detector *AnomalyDetector) judge(key rateKey, stats *rateStats, count int) {
+	// counts are at least as spread as a Poisson process of the same rate
+	deviation := math.Max(math.Sqrt(stats.variance), math.Sqrt(math.Max(stats.mean, 1)))
+	score := (float64(count) - stats.mean) / deviation
+	kind := ""
+	switch {
+	case score >= anomaly_score && float64(count)-stats.mean >= anomaly_min_burst:
+		kind = AnomalyBurst
+	case count == 0 && stats.mean >= anomaly_min_stopped_rate && !stats.stopped:
+		kind = AnomalyStopped
+	}
+
+	stats.stopped = count == 0 && (stats.stopped || kind == AnomalyStopped)
+	if kind == "" {
+		return
+	}
+
+	anomaly := &Anomaly{
+		Kind:      kind,
+		Level:     key.level,
+		PatternId: key.patternId,
+		Minute:    detector.minute,
+		Count:     count,
+		Expected:  stats.mean,
+		Score:     score,
+	}
+
+	if key.patternId != "" && detector.patterns != nil {
+		if pattern := detector.patterns.Get(key.patternId); pattern != nil {
+			anomaly.Template = pattern.Template
+		}
+	}
+
+	detector.anomalies = append(detector.anomalies, anomaly)
+	if len(detector.anomalies) > max_anomalies {
+		detector.anomalies = detector.anomalies[len(detector.anomalies)-max_anomalies:]
+	}
+}
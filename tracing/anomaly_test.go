@@ -0,0 +1,95 @@
+package tracing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type anomalyClock struct {
+	now time.Time
+}
+
+func getAnomalyDetector(miner *PatternMiner) (*AnomalyDetector, *anomalyClock) {
+	clock := &anomalyClock{now: time.Date(2022, 4, 4, 10, 0, 0, 0, time.UTC)}
+	detector := NewAnomalyDetector(miner)
+	detector.now = func() time.Time { return clock.now }
+	return detector, clock
+}
+
+// observes the traces spread over the next minute
+func observeMinute(detector *AnomalyDetector, clock *anomalyClock, traces ...*Trace) {
+	start := clock.now
+	for i, trc := range traces {
+		clock.now = start.Add(time.Duration(i) * time.Minute / time.Duration(len(traces)+1))
+		detector.Observe(trc)
+	}
+	clock.now = start.Add(time.Minute)
+}
+
+func repeat(n int, message, level string) []*Trace {
+	traces := make([]*Trace, 0, n)
+	for i := 0; i < n; i++ {
+		traces = append(traces, NewTrace(time.Now(), message, "1", level))
+	}
+	return traces
+}
+
+func Test_anomaly_burst_of_warnings(t *testing.T) {
+	detector, clock := getAnomalyDetector(nil)
+	for i := 0; i < 10; i++ {
+		observeMinute(detector, clock, append(repeat(20, "ok", "info"), repeat(i%2, "hmm", "warn")...)...)
+	}
+	assert.Equal(t, 0, len(detector.Anomalies()))
+
+	observeMinute(detector, clock, append(repeat(20, "ok", "info"), repeat(30, "hmm", "warn")...)...)
+	anomalies := detector.Anomalies()
+	assert.Equal(t, 1, len(anomalies))
+	assert.Equal(t, AnomalyBurst, anomalies[0].Kind)
+	assert.Equal(t, "warn", anomalies[0].Level)
+	assert.Equal(t, 30, anomalies[0].Count)
+	assert.True(t, anomalies[0].Score >= anomaly_score)
+}
+
+func Test_anomaly_pattern_stopped_appearing(t *testing.T) {
+	miner := NewPatternMiner()
+	detector, clock := getAnomalyDetector(miner)
+	heartbeat := func(n int) []*Trace {
+		traces := make([]*Trace, 0)
+		for i := 0; i < n; i++ {
+			traces = append(traces, mine(miner, "heartbeat from node 7"))
+		}
+		return traces
+	}
+
+	for i := 0; i < 10; i++ {
+		observeMinute(detector, clock, heartbeat(6)...)
+	}
+	assert.Equal(t, 0, len(detector.Anomalies()))
+
+	// two quiet minutes only report the stop once
+	clock.now = clock.now.Add(2 * time.Minute)
+	anomalies := detector.Anomalies()
+	stopped := make([]*Anomaly, 0)
+	for _, anomaly := range anomalies {
+		if anomaly.PatternId != "" {
+			stopped = append(stopped, anomaly)
+		}
+	}
+
+	assert.Equal(t, 1, len(stopped))
+	assert.Equal(t, AnomalyStopped, stopped[0].Kind)
+	assert.Equal(t, "heartbeat from node <*>", stopped[0].Template)
+	assert.Equal(t, 0, stopped[0].Count)
+}
+
+func Test_anomaly_needs_history(t *testing.T) {
+	detector, clock := getAnomalyDetector(nil)
+	observeMinute(detector, clock, repeat(1, "hmm", "warn")...)
+	observeMinute(detector, clock, repeat(100, "hmm", "warn")...)
+	assert.Equal(t, 0, len(detector.Anomalies()))
+
+	detector.Reset()
+	assert.Equal(t, 0, len(detector.stats))
+}
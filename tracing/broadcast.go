@@ -0,0 +1,77 @@
+package tracing
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+const subscription_buffer = 1000
+
+// Subscription receives the traces satisfying its match as they are ingested.
+// Traces are dropped rather than holding up ingestion when the subscriber falls behind.
+type Subscription struct {
+	C       chan *Trace
+	match   *Match
+	dropped uint64
+}
+
+// Dropped returns the number of traces the subscriber was too slow to receive
+func (subscription *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&subscription.dropped)
+}
+
+// Broadcaster hands every trace coming out of the pipeline to the live subscriptions
+type Broadcaster struct {
+	lock          sync.RWMutex
+	subscriptions map[*Subscription]bool
+}
+
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{
+		subscriptions: make(map[*Subscription]bool),
+	}
+}
+
+// Subscribe to the traces satisfying the match, all of them if it is nil
+func (broadcaster *Broadcaster) Subscribe(match *Match) *Subscription {
+	subscription := &Subscription{
+		C:     make(chan *Trace, subscription_buffer),
+		match: match,
+	}
+
+	broadcaster.lock.Lock()
+	defer broadcaster.lock.Unlock()
+	broadcaster.subscriptions[subscription] = true
+	return subscription
+}
+
+// Unsubscribe stops the subscription and closes its channel
+func (broadcaster *Broadcaster) Unsubscribe(subscription *Subscription) {
+	broadcaster.lock.Lock()
+	defer broadcaster.lock.Unlock()
+	if broadcaster.subscriptions[subscription] {
+		delete(broadcaster.subscriptions, subscription)
+		close(subscription.C)
+	}
+}
+
+func (broadcaster *Broadcaster) Publish(trc *Trace) {
+	broadcaster.lock.RLock()
+	defer broadcaster.lock.RUnlock()
+	for subscription := range broadcaster.subscriptions {
+		if subscription.match != nil && !subscription.match.IsMatch(trc) {
+			continue
+		}
+
+		select {
+		case subscription.C <- trc:
+		default:
+			atomic.AddUint64(&subscription.dropped, 1)
+		}
+	}
+}
+
+func (broadcaster *Broadcaster) Process(entry *Entry) ([]*Entry, error) {
+	broadcaster.Publish(entry.Trace)
+	return []*Entry{entry}, nil
+}
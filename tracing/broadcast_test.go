@@ -0,0 +1,40 @@
+package tracing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_broadcast_to_matching_subscriptions(t *testing.T) {
+	broadcaster := NewBroadcaster()
+	match := &Match{CorrelationId: "1"}
+	assert.Nil(t, match.Compile())
+	all := broadcaster.Subscribe(nil)
+	one := broadcaster.Subscribe(match)
+
+	_, err := broadcaster.Process(&Entry{Trace: NewTrace(time.Now(), "first", "1", "info")})
+	assert.Nil(t, err)
+	broadcaster.Publish(NewTrace(time.Now(), "second", "2", "info"))
+
+	assert.Equal(t, 2, len(all.C))
+	assert.Equal(t, 1, len(one.C))
+	assert.Equal(t, "first", (<-one.C).Message)
+
+	broadcaster.Unsubscribe(one)
+	broadcaster.Unsubscribe(one)
+	_, open := <-one.C
+	assert.False(t, open)
+}
+
+func Test_broadcast_drops_for_slow_subscriptions(t *testing.T) {
+	broadcaster := NewBroadcaster()
+	subscription := broadcaster.Subscribe(nil)
+	for i := 0; i < subscription_buffer+5; i++ {
+		broadcaster.Publish(NewTrace(time.Now(), "spam", "1", "info"))
+	}
+
+	assert.Equal(t, subscription_buffer, len(subscription.C))
+	assert.Equal(t, uint64(5), subscription.Dropped())
+}
@@ -5,6 +5,8 @@ type Config struct {
 	MessageFieldNames       []string
 	LevelFieldNames         []string
 	CorrelationIdFieldNames []string
+	SpanIdFieldNames        []string
+	ParentSpanIdFieldNames  []string
 	IndexableFieldNames     []string
 	KeepOriginalPayload     bool
 }
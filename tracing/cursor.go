@@ -0,0 +1,55 @@
+package tracing
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// Cursor is a position in the time ordered traces. Traces with the same timestamp
+// are ordered by their id so paging never skips or repeats any of them.
+// A cursor without a TraceId sits before all traces of its TimeIndex.
+type Cursor struct {
+	TimeIndex string
+	TraceId   string
+}
+
+type Page struct {
+	Traces  []*Trace // in time order
+	HasMore bool     // whether there are more traces in the direction of paging
+}
+
+func CursorOf(trc *Trace) *Cursor {
+	return &Cursor{
+		TimeIndex: trc.TimeIndex,
+		TraceId:   trc.TraceId,
+	}
+}
+
+// Encode returns the cursor as an opaque URL-safe string
+func (cursor *Cursor) Encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursor.TimeIndex + ":" + cursor.TraceId))
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	parts := strings.SplitN(string(data), ":", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return nil, errors.New("invalid cursor")
+	}
+
+	return &Cursor{TimeIndex: parts[0], TraceId: parts[1]}, nil
+}
+
+// compares the position of the trace to the cursor in the order of the timestamp index
+func (cursor *Cursor) compare(trc *Trace) int {
+	c := strings.Compare(trc.TimeIndex, cursor.TimeIndex)
+	if c != 0 {
+		return c
+	}
+	return strings.Compare(trc.TraceId, cursor.TraceId)
+}
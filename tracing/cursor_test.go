@@ -0,0 +1,90 @@
+package tracing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_cursor_round_trip(t *testing.T) {
+	trc := NewTrace(time.Now(), "hello", "12345", "info")
+	cursor, err := DecodeCursor(CursorOf(trc).Encode())
+	assert.Nil(t, err)
+	assert.Equal(t, trc.TimeIndex, cursor.TimeIndex)
+	assert.Equal(t, trc.TraceId, cursor.TraceId)
+
+	_, err = DecodeCursor("not a cursor")
+	assert.NotNil(t, err)
+}
+
+// many traces sharing timestamps, which paging by timestamp alone would lose
+func getPagingStore(t *testing.T) (*InMemoryStore, map[string]bool) {
+	store, err := NewInMemoryStore(EmptyConfig())
+	assert.Nil(t, err)
+	ids := make(map[string]bool)
+	start := time.Now().UTC().Add(-1 * time.Hour)
+	for i := 0; i < 250; i++ {
+		trc := NewTrace(start.Add(time.Duration(i/10)*time.Second), "hello", "12345", "info")
+		ids[trc.TraceId] = true
+		_ = store.Store(trc, "")
+	}
+	return store, ids
+}
+
+func Test_paging_forward_visits_every_trace_once(t *testing.T) {
+	store, ids := getPagingStore(t)
+	seen := make(map[string]bool)
+	var cursor *Cursor
+	pages := 0
+	for {
+		page, err := store.ListPage(30, nil, nil, nil, cursor, false)
+		assert.Nil(t, err)
+		for _, trc := range page.Traces {
+			assert.False(t, seen[trc.TraceId])
+			seen[trc.TraceId] = true
+		}
+		pages++
+		if !page.HasMore {
+			break
+		}
+		cursor = CursorOf(page.Traces[len(page.Traces)-1])
+	}
+
+	assert.Equal(t, len(ids), len(seen))
+	assert.Equal(t, 9, pages)
+}
+
+func Test_paging_backward_visits_every_trace_once(t *testing.T) {
+	store, ids := getPagingStore(t)
+	seen := make(map[string]bool)
+	to := time.Now().UTC()
+	var cursor *Cursor
+	var previous *Trace
+	for {
+		page, err := store.ListPage(25, nil, &to, nil, cursor, true)
+		assert.Nil(t, err)
+		for i, trc := range page.Traces {
+			assert.False(t, seen[trc.TraceId])
+			seen[trc.TraceId] = true
+			if i == len(page.Traces)-1 && previous != nil {
+				assert.True(t, CursorOf(previous).compare(trc) < 0)
+			}
+		}
+		if !page.HasMore {
+			break
+		}
+		previous = page.Traces[0]
+		cursor = CursorOf(page.Traces[0])
+	}
+
+	assert.Equal(t, len(ids), len(seen))
+}
+
+func Test_paging_caps_at_max_return(t *testing.T) {
+	store, _ := getPagingStore(t)
+	page, err := store.ListPage(1000, nil, nil, nil, nil, false)
+	assert.Nil(t, err)
+	assert.Equal(t, max_return, len(page.Traces))
+	assert.True(t, page.HasMore)
+}
@@ -0,0 +1,132 @@
+package tracing
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	CollapseMessage = "message"
+	CollapsePattern = "pattern"
+
+	dedup_sweep_at = 10000 // keys held before expired windows are swept
+)
+
+// CollapsedTrace is the first trace of a run of repeats, with the run's count and time span
+type CollapsedTrace struct {
+	*Trace
+	Repeats       int
+	LastTimestamp time.Time
+	LastTraceId   string
+}
+
+// the key of repeats, by pattern falls back to the message for traces without a pattern
+func repeatKey(trc *Trace, by string) string {
+	text := trc.Message
+	if by == CollapsePattern && trc.PatternId != "" {
+		text = trc.PatternId
+	}
+	return text + "\x00" + trc.Level + "\x00" + trc.CorrelationId
+}
+
+// Collapse folds consecutive traces with the same message, or pattern, level and correlation id into one
+func Collapse(traces []*Trace, by string) []*CollapsedTrace {
+	collapsed := make([]*CollapsedTrace, 0)
+	var last *CollapsedTrace
+	lastKey := ""
+	for _, trc := range traces {
+		key := repeatKey(trc, by)
+		if last != nil && key == lastKey {
+			last.Repeats++
+			last.LastTimestamp = trc.Timestamp
+			last.LastTraceId = trc.TraceId
+			continue
+		}
+
+		last = &CollapsedTrace{
+			Trace:         trc,
+			Repeats:       1,
+			LastTimestamp: trc.Timestamp,
+			LastTraceId:   trc.TraceId,
+		}
+		lastKey = key
+		collapsed = append(collapsed, last)
+	}
+	return collapsed
+}
+
+type dedupWindow struct {
+	start time.Time
+	count int
+}
+
+// Deduplicator only lets through the first Max traces of the same message, or pattern,
+// level and correlation id in every window, which starts with the first of them
+type Deduplicator struct {
+	Max     int
+	Window  time.Duration
+	By      string
+	lock    sync.Mutex
+	windows map[string]*dedupWindow
+	dropped uint64
+}
+
+func NewDeduplicator(max int, window time.Duration, by string) *Deduplicator {
+	return &Deduplicator{
+		Max:     max,
+		Window:  window,
+		By:      by,
+		windows: make(map[string]*dedupWindow),
+	}
+}
+
+func (dedup *Deduplicator) Process(entry *Entry) ([]*Entry, error) {
+	if dedup.Allow(entry.Trace) {
+		return []*Entry{entry}, nil
+	}
+	return []*Entry{}, nil
+}
+
+// Allow counts the trace in its window and tells whether it is within the first Max
+func (dedup *Deduplicator) Allow(trc *Trace) bool {
+	if dedup.Max <= 0 {
+		return true
+	}
+
+	dedup.lock.Lock()
+	defer dedup.lock.Unlock()
+	if len(dedup.windows) >= dedup_sweep_at {
+		for key, window := range dedup.windows {
+			if trc.Timestamp.Sub(window.start) >= dedup.Window {
+				delete(dedup.windows, key)
+			}
+		}
+	}
+
+	key := repeatKey(trc, dedup.By)
+	window, ok := dedup.windows[key]
+	if !ok || trc.Timestamp.Sub(window.start) >= dedup.Window {
+		window = &dedupWindow{start: trc.Timestamp}
+		dedup.windows[key] = window
+	}
+
+	window.count++
+	if window.count > dedup.Max {
+		atomic.AddUint64(&dedup.dropped, 1)
+		return false
+	}
+	return true
+}
+
+// Reset forgets the windows, so repeats of deleted traces are let through again
+func (dedup *Deduplicator) Reset() {
+	dedup.lock.Lock()
+	defer dedup.lock.Unlock()
+	dedup.windows = make(map[string]*dedupWindow)
+}
+
+// Dropped returns the number of duplicates not let through
+func (dedup *Deduplicator) Dropped() uint64 {
+	return atomic.LoadUint64(&dedup.dropped)
+}
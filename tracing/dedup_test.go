@@ -0,0 +1,69 @@
+package tracing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_collapse_consecutive_repeats(t *testing.T) {
+	ts := time.Date(2022, 4, 4, 10, 0, 0, 0, time.UTC)
+	traces := []*Trace{
+		NewTrace(ts, "retrying", "1", "warn"),
+		NewTrace(ts.Add(time.Second), "retrying", "1", "warn"),
+		NewTrace(ts.Add(2*time.Second), "retrying", "1", "warn"),
+		NewTrace(ts.Add(3*time.Second), "retrying", "2", "warn"),
+		NewTrace(ts.Add(4*time.Second), "gave up", "1", "error"),
+		NewTrace(ts.Add(5*time.Second), "retrying", "1", "warn"),
+	}
+
+	collapsed := Collapse(traces, CollapseMessage)
+	assert.Equal(t, 4, len(collapsed))
+	assert.Equal(t, 3, collapsed[0].Repeats)
+	assert.Equal(t, traces[0].TraceId, collapsed[0].TraceId)
+	assert.Equal(t, ts.Add(2*time.Second), collapsed[0].LastTimestamp)
+	assert.Equal(t, traces[2].TraceId, collapsed[0].LastTraceId)
+	assert.Equal(t, 1, collapsed[1].Repeats)
+	assert.Equal(t, 1, collapsed[3].Repeats)
+}
+
+func Test_collapse_by_pattern(t *testing.T) {
+	miner := NewPatternMiner()
+	traces := []*Trace{mine(miner, "took 1 ms"), mine(miner, "took 2 ms")}
+	assert.Equal(t, 2, len(Collapse(traces, CollapseMessage)))
+	assert.Equal(t, 1, len(Collapse(traces, CollapsePattern)))
+}
+
+func Test_deduplicator_keeps_first_n_per_window(t *testing.T) {
+	dedup := NewDeduplicator(2, time.Minute, CollapseMessage)
+	ts := time.Date(2022, 4, 4, 10, 0, 0, 0, time.UTC)
+	kept := 0
+	for i := 0; i < 10; i++ {
+		entries, err := dedup.Process(&Entry{Trace: NewTrace(ts.Add(time.Duration(i)*time.Second), "spam", "1", "info")})
+		assert.Nil(t, err)
+		kept += len(entries)
+	}
+
+	assert.Equal(t, 2, kept)
+	assert.Equal(t, uint64(8), dedup.Dropped())
+	assert.True(t, dedup.Allow(NewTrace(ts.Add(10*time.Second), "spam", "2", "info")))
+	assert.True(t, dedup.Allow(NewTrace(ts.Add(time.Minute), "spam", "1", "info")))
+}
+
+func Test_deduplicator_reset(t *testing.T) {
+	dedup := NewDeduplicator(1, time.Minute, CollapseMessage)
+	ts := time.Date(2022, 4, 4, 10, 0, 0, 0, time.UTC)
+	assert.True(t, dedup.Allow(NewTrace(ts, "spam", "1", "info")))
+	assert.False(t, dedup.Allow(NewTrace(ts.Add(time.Second), "spam", "1", "info")))
+
+	dedup.Reset()
+	assert.True(t, dedup.Allow(NewTrace(ts.Add(2*time.Second), "spam", "1", "info")))
+}
+
+func Test_deduplicator_disabled(t *testing.T) {
+	dedup := NewDeduplicator(0, time.Minute, CollapsePattern)
+	for i := 0; i < 10; i++ {
+		assert.True(t, dedup.Allow(NewTrace(time.Now(), "spam", "1", "info")))
+	}
+}
@@ -0,0 +1,194 @@
+package tracing
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	DiffSame      = "same"
+	DiffInserted  = "inserted"  // only on the right
+	DiffMissing   = "missing"   // only on the left
+	DiffReordered = "reordered" // on both sides but out of order
+
+	max_diff_traces = 2000
+)
+
+var ErrDiffTooLarge = errors.New("too many traces to diff")
+
+type FieldChange struct {
+	Name  string // Level, Properties.name or Metrics.name
+	Left  string
+	Right string
+}
+
+type DiffStep struct {
+	Kind          string
+	Left          *Trace
+	Right         *Trace
+	LeftOffsetMs  float64 // since the first trace of its side
+	RightOffsetMs float64
+	DeltaMs       float64 // how much later the step happened on the right, relative to the start
+	Changes       []*FieldChange
+}
+
+type Diff struct {
+	Steps           []*DiffStep
+	Same            int
+	Inserted        int
+	Missing         int
+	Reordered       int
+	LeftDurationMs  float64
+	RightDurationMs float64
+}
+
+// the step a trace stands for, its pattern or failing that its message
+func stepKey(trc *Trace) string {
+	if trc.PatternId != "" {
+		return "\x00" + trc.PatternId
+	}
+	return trc.Message
+}
+
+// DiffTraces aligns two time ordered sequences of traces by message pattern, using their longest
+// common subsequence. Steps left over on both sides with the same pattern are paired as reordered.
+// Steps follow the right side, missing ones come before what was inserted in their place.
+func DiffTraces(left, right []*Trace) (*Diff, error) {
+	if len(left) > max_diff_traces || len(right) > max_diff_traces {
+		return nil, ErrDiffTooLarge
+	}
+
+	// lengths[i][j] is the longest common subsequence of left[i:] and right[j:]
+	lengths := make([][]int32, len(left)+1)
+	for i := range lengths {
+		lengths[i] = make([]int32, len(right)+1)
+	}
+	for i := len(left) - 1; i >= 0; i-- {
+		for j := len(right) - 1; j >= 0; j-- {
+			if stepKey(left[i]) == stepKey(right[j]) {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	steps := make([]*DiffStep, 0, len(left)+len(right))
+	i, j := 0, 0
+	for i < len(left) || j < len(right) {
+		switch {
+		case i < len(left) && j < len(right) && stepKey(left[i]) == stepKey(right[j]):
+			steps = append(steps, &DiffStep{Kind: DiffSame, Left: left[i], Right: right[j]})
+			i++
+			j++
+		case i < len(left) && (j == len(right) || lengths[i+1][j] >= lengths[i][j+1]):
+			steps = append(steps, &DiffStep{Kind: DiffMissing, Left: left[i]})
+			i++
+		default:
+			steps = append(steps, &DiffStep{Kind: DiffInserted, Right: right[j]})
+			j++
+		}
+	}
+
+	// pairs leftovers of the same pattern, the missing step folds into the inserted one
+	inserted := make(map[string][]*DiffStep)
+	for _, step := range steps {
+		if step.Kind == DiffInserted {
+			key := stepKey(step.Right)
+			inserted[key] = append(inserted[key], step)
+		}
+	}
+
+	diff := &Diff{Steps: make([]*DiffStep, 0, len(steps))}
+	for _, step := range steps {
+		if step.Kind == DiffMissing {
+			key := stepKey(step.Left)
+			if candidates := inserted[key]; len(candidates) > 0 {
+				candidates[0].Kind = DiffReordered
+				candidates[0].Left = step.Left
+				inserted[key] = candidates[1:]
+				continue
+			}
+		}
+		diff.Steps = append(diff.Steps, step)
+	}
+
+	var leftStart, rightStart time.Time
+	if len(left) > 0 {
+		leftStart = left[0].Timestamp
+		diff.LeftDurationMs = toMs(left[len(left)-1].Timestamp.Sub(leftStart))
+	}
+	if len(right) > 0 {
+		rightStart = right[0].Timestamp
+		diff.RightDurationMs = toMs(right[len(right)-1].Timestamp.Sub(rightStart))
+	}
+
+	for _, step := range diff.Steps {
+		if step.Left != nil {
+			step.LeftOffsetMs = toMs(step.Left.Timestamp.Sub(leftStart))
+		}
+		if step.Right != nil {
+			step.RightOffsetMs = toMs(step.Right.Timestamp.Sub(rightStart))
+		}
+
+		step.Changes = make([]*FieldChange, 0)
+		switch step.Kind {
+		case DiffSame:
+			diff.Same++
+		case DiffInserted:
+			diff.Inserted++
+		case DiffMissing:
+			diff.Missing++
+		case DiffReordered:
+			diff.Reordered++
+		}
+
+		if step.Left != nil && step.Right != nil {
+			step.DeltaMs = step.RightOffsetMs - step.LeftOffsetMs
+			step.Changes = fieldChanges(step.Left, step.Right)
+		}
+	}
+
+	return diff, nil
+}
+
+// the level, properties and metrics which differ between the traces, sorted by name
+func fieldChanges(left, right *Trace) []*FieldChange {
+	changes := make([]*FieldChange, 0)
+	if left.Level != right.Level {
+		changes = append(changes, &FieldChange{Name: "Level", Left: left.Level, Right: right.Level})
+	}
+
+	values := func(trc *Trace) map[string]string {
+		fields := make(map[string]string)
+		for name, value := range trc.Properties {
+			fields["Properties."+name] = value
+		}
+		for name, value := range trc.Metrics {
+			fields["Metrics."+name] = strconv.FormatFloat(value, 'f', -1, 64)
+		}
+		return fields
+	}
+
+	leftValues, rightValues := values(left), values(right)
+	fieldChanges := make([]*FieldChange, 0)
+	for name, value := range leftValues {
+		if rightValues[name] != value {
+			fieldChanges = append(fieldChanges, &FieldChange{Name: name, Left: value, Right: rightValues[name]})
+		}
+	}
+	for name, value := range rightValues {
+		if _, ok := leftValues[name]; !ok {
+			fieldChanges = append(fieldChanges, &FieldChange{Name: name, Right: value})
+		}
+	}
+
+	sort.Slice(fieldChanges, func(i, j int) bool {
+		return fieldChanges[i].Name < fieldChanges[j].Name
+	})
+	return append(changes, fieldChanges...)
+}
@@ -0,0 +1,82 @@
+package tracing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getFlow(start time.Time, corrId string, messages ...string) []*Trace {
+	traces := make([]*Trace, 0)
+	for i, message := range messages {
+		traces = append(traces, NewTrace(start.Add(time.Duration(i)*10*time.Millisecond), message, corrId, "info"))
+	}
+	return traces
+}
+
+func Test_diff_identical_flows(t *testing.T) {
+	start := time.Date(2022, 4, 4, 10, 0, 0, 0, time.UTC)
+	diff, err := DiffTraces(getFlow(start, "a", "start", "auth", "done"), getFlow(start.Add(time.Hour), "b", "start", "auth", "done"))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, diff.Same)
+	assert.Equal(t, 0, diff.Inserted+diff.Missing+diff.Reordered)
+	assert.Equal(t, 20.0, diff.LeftDurationMs)
+	assert.Equal(t, 0.0, diff.Steps[2].DeltaMs)
+}
+
+func Test_diff_inserted_missing_and_reordered(t *testing.T) {
+	start := time.Date(2022, 4, 4, 10, 0, 0, 0, time.UTC)
+	left := getFlow(start, "a", "start", "load cache", "auth", "query", "done")
+	right := getFlow(start, "b", "start", "auth", "query", "load cache", "retry", "failed")
+
+	diff, err := DiffTraces(left, right)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, diff.Same)
+	assert.Equal(t, 1, diff.Reordered)
+	assert.Equal(t, 2, diff.Inserted)
+	assert.Equal(t, 1, diff.Missing)
+
+	kinds := make([]string, 0)
+	for _, step := range diff.Steps {
+		kinds = append(kinds, step.Kind)
+	}
+	assert.Equal(t, []string{DiffSame, DiffSame, DiffSame, DiffMissing, DiffReordered, DiffInserted, DiffInserted}, kinds)
+
+	reordered := diff.Steps[4]
+	assert.Equal(t, "load cache", reordered.Left.Message)
+	assert.Equal(t, 10.0, reordered.LeftOffsetMs)
+	assert.Equal(t, 30.0, reordered.RightOffsetMs)
+	assert.Equal(t, 20.0, reordered.DeltaMs)
+}
+
+func Test_diff_field_changes(t *testing.T) {
+	start := time.Date(2022, 4, 4, 10, 0, 0, 0, time.UTC)
+	left := getFlow(start, "a", "query")
+	right := getFlow(start, "b", "query")
+	left[0].Properties["status"] = "200"
+	left[0].Properties["user"] = "jo"
+	left[0].Metrics["took"] = 12
+	right[0].Properties["status"] = "500"
+	right[0].Properties["user"] = "jo"
+	right[0].Properties["error"] = "timeout"
+	right[0].Level = "error"
+
+	diff, err := DiffTraces(left, right)
+	assert.Nil(t, err)
+	assert.Equal(t, []*FieldChange{
+		{Name: "Level", Left: "info", Right: "error"},
+		{Name: "Metrics.took", Left: "12"},
+		{Name: "Properties.error", Right: "timeout"},
+		{Name: "Properties.status", Left: "200", Right: "500"},
+	}, diff.Steps[0].Changes)
+}
+
+func Test_diff_by_pattern(t *testing.T) {
+	miner := NewPatternMiner()
+	left := []*Trace{mine(miner, "took 1 ms")}
+	right := []*Trace{mine(miner, "took 25 ms")}
+	diff, err := DiffTraces(left, right)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, diff.Same)
+}
@@ -0,0 +1,315 @@
+package tracing
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	ExportNdjson = "ndjson"
+	ExportCsv    = "csv"
+	ExportClef   = "clef"
+	ExportOtlp   = "otlp"
+)
+
+var defaultCsvColumns = []string{"Timestamp", "Level", "Message", "CorrelationId", "TraceId"}
+
+// ExportWriter writes traces one at a time so exports can be streamed
+type ExportWriter interface {
+	Write(trc *Trace) error
+	Close() error // writes whatever the format needs at the end, does not close the underlying writer
+}
+
+// NewExportWriter returns a writer for the format. Columns only apply to CSV and can be
+// trace fields, Properties.name or Metrics.name.
+func NewExportWriter(format string, w io.Writer, columns []string) (ExportWriter, error) {
+	switch format {
+	case ExportNdjson:
+		return &ndjsonWriter{encoder: json.NewEncoder(w)}, nil
+	case ExportCsv:
+		if len(columns) == 0 {
+			columns = defaultCsvColumns
+		}
+		for _, column := range columns {
+			if _, ok := csvFields[column]; !ok && !strings.HasPrefix(column, "Properties.") && !strings.HasPrefix(column, "Metrics.") {
+				return nil, fmt.Errorf("unknown column %q", column)
+			}
+		}
+		return &csvWriter{writer: csv.NewWriter(w), columns: columns}, nil
+	case ExportClef:
+		return &clefWriter{encoder: json.NewEncoder(w)}, nil
+	case ExportOtlp:
+		return &otlpWriter{w: w}, nil
+	default:
+		return nil, fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+// ExportContentType returns the media type of an export format
+func ExportContentType(format string) string {
+	switch format {
+	case ExportCsv:
+		return "text/csv"
+	case ExportOtlp:
+		return "application/json"
+	default:
+		return "application/x-ndjson"
+	}
+}
+
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+func (writer *ndjsonWriter) Write(trc *Trace) error {
+	return writer.encoder.Encode(trc)
+}
+
+func (writer *ndjsonWriter) Close() error {
+	return nil
+}
+
+var csvFields = map[string]func(trc *Trace) string{
+	"Timestamp":     func(trc *Trace) string { return trc.Timestamp.Format(time.RFC3339Nano) },
+	"Level":         func(trc *Trace) string { return trc.Level },
+	"Message":       func(trc *Trace) string { return trc.Message },
+	"CorrelationId": func(trc *Trace) string { return trc.CorrelationId },
+	"TraceId":       func(trc *Trace) string { return trc.TraceId },
+	"SpanId":        func(trc *Trace) string { return trc.SpanId },
+	"ParentSpanId":  func(trc *Trace) string { return trc.ParentSpanId },
+	"Source":        func(trc *Trace) string { return trc.Source },
+	"SessionId":     func(trc *Trace) string { return trc.SessionId },
+	"PatternId":     func(trc *Trace) string { return trc.PatternId },
+}
+
+type csvWriter struct {
+	writer        *csv.Writer
+	columns       []string
+	headerWritten bool
+}
+
+func (writer *csvWriter) Write(trc *Trace) error {
+	if !writer.headerWritten {
+		writer.headerWritten = true
+		err := writer.writer.Write(writer.columns)
+		if err != nil {
+			return err
+		}
+	}
+
+	record := make([]string, len(writer.columns))
+	for i, column := range writer.columns {
+		if field, ok := csvFields[column]; ok {
+			record[i] = field(trc)
+		} else if strings.HasPrefix(column, "Metrics.") {
+			if value, ok := trc.Metrics[strings.TrimPrefix(column, "Metrics.")]; ok {
+				record[i] = strconv.FormatFloat(value, 'f', -1, 64)
+			}
+		} else {
+			record[i] = trc.Properties[strings.TrimPrefix(column, "Properties.")]
+		}
+	}
+
+	err := writer.writer.Write(record)
+	if err != nil {
+		return err
+	}
+	writer.writer.Flush()
+	return writer.writer.Error()
+}
+
+func (writer *csvWriter) Close() error {
+	if !writer.headerWritten {
+		writer.headerWritten = true
+		err := writer.writer.Write(writer.columns)
+		if err != nil {
+			return err
+		}
+	}
+	writer.writer.Flush()
+	return writer.writer.Error()
+}
+
+type clefWriter struct {
+	encoder *json.Encoder
+}
+
+// ToClef returns the trace as a CLEF event which PayloadParser reads back into an equivalent trace
+func ToClef(trc *Trace) map[string]interface{} {
+	event := make(map[string]interface{})
+	for name, value := range trc.Properties {
+		event[name] = value
+	}
+	for name, value := range trc.Metrics {
+		event[name] = value
+	}
+
+	event["@t"] = trc.Timestamp.Format(time.RFC3339Nano)
+	event["@m"] = trc.Message
+	event["@l"] = trc.Level
+	if trc.CorrelationId != "" {
+		event["@tr"] = trc.CorrelationId
+	}
+	if trc.SpanId != "" {
+		event["@sp"] = trc.SpanId
+	}
+	if trc.ParentSpanId != "" {
+		event["@ps"] = trc.ParentSpanId
+	}
+	return event
+}
+
+func (writer *clefWriter) Write(trc *Trace) error {
+	return writer.encoder.Encode(ToClef(trc))
+}
+
+func (writer *clefWriter) Close() error {
+	return nil
+}
+
+// writes a single OTLP/JSON logs document, see https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+type otlpWriter struct {
+	w       io.Writer
+	started bool
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano         string         `json:"timeUnixNano"`
+	ObservedTimeUnixNano string         `json:"observedTimeUnixNano"`
+	SeverityNumber       int            `json:"severityNumber"`
+	SeverityText         string         `json:"severityText"`
+	Body                 otlpAnyValue   `json:"body"`
+	Attributes           []otlpKeyValue `json:"attributes"`
+	TraceId              string         `json:"traceId,omitempty"`
+	SpanId               string         `json:"spanId,omitempty"`
+}
+
+const (
+	otlpPrefix = `{"resourceLogs":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"traceview"}}]},"scopeLogs":[{"scope":{"name":"traceview"},"logRecords":[`
+	otlpSuffix = "]}]}]}\n"
+)
+
+// ToOtlpLogRecord maps a trace to an OTLP log record. Ids that are not valid OTLP ids become attributes.
+func ToOtlpLogRecord(trc *Trace) interface{} {
+	message := trc.Message
+	level := trc.Level
+	nanos := strconv.FormatInt(trc.Timestamp.UnixNano(), 10)
+	record := otlpLogRecord{
+		TimeUnixNano:         nanos,
+		ObservedTimeUnixNano: nanos,
+		SeverityNumber:       SeverityNumber(trc.Level),
+		SeverityText:         level,
+		Body:                 otlpAnyValue{StringValue: &message},
+		Attributes:           make([]otlpKeyValue, 0, len(trc.Properties)+len(trc.Metrics)),
+	}
+
+	addString := func(key, value string) {
+		record.Attributes = append(record.Attributes, otlpKeyValue{Key: key, Value: otlpAnyValue{StringValue: &value}})
+	}
+
+	if isHexId(trc.CorrelationId, 16) {
+		record.TraceId = strings.ToLower(trc.CorrelationId)
+	} else if trc.CorrelationId != "" {
+		addString("correlation.id", trc.CorrelationId)
+	}
+
+	if isHexId(trc.SpanId, 8) {
+		record.SpanId = strings.ToLower(trc.SpanId)
+	} else if trc.SpanId != "" {
+		addString("span.id", trc.SpanId)
+	}
+
+	if trc.ParentSpanId != "" {
+		addString("parent.span.id", trc.ParentSpanId)
+	}
+
+	propertyNames := maps.Keys(trc.Properties)
+	slices.Sort(propertyNames)
+	for _, name := range propertyNames {
+		addString(name, trc.Properties[name])
+	}
+
+	metricNames := maps.Keys(trc.Metrics)
+	slices.Sort(metricNames)
+	for _, name := range metricNames {
+		value := trc.Metrics[name]
+		record.Attributes = append(record.Attributes, otlpKeyValue{Key: name, Value: otlpAnyValue{DoubleValue: &value}})
+	}
+
+	return record
+}
+
+func (writer *otlpWriter) Write(trc *Trace) error {
+	separator := ","
+	if !writer.started {
+		writer.started = true
+		separator = otlpPrefix
+	}
+
+	data, err := json.Marshal(ToOtlpLogRecord(trc))
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(writer.w, separator+string(data))
+	return err
+}
+
+func (writer *otlpWriter) Close() error {
+	if !writer.started {
+		writer.started = true
+		_, err := io.WriteString(writer.w, otlpPrefix)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(writer.w, otlpSuffix)
+	return err
+}
+
+// SeverityNumber maps a level to the OpenTelemetry severity number of its range
+func SeverityNumber(level string) int {
+	switch strings.ToLower(level) {
+	case "trace", "verbose":
+		return 1
+	case "debug":
+		return 5
+	case "info", "information":
+		return 9
+	case "warn", "warning":
+		return 13
+	case "error":
+		return 17
+	case "fatal", "critical":
+		return 21
+	default:
+		return 0
+	}
+}
+
+func isHexId(id string, size int) bool {
+	if len(id) != size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
@@ -0,0 +1,82 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getExportTraces() []*Trace {
+	ts := time.Date(2022, 4, 4, 10, 0, 0, 0, time.UTC)
+	first := NewTrace(ts, "hello, world", "4bf92f3577b34da6a3ce929d0e0e4736", "info")
+	first.SpanId = "00f067aa0ba902b7"
+	first.Properties["user"] = "jo"
+	first.Metrics["took"] = 12
+	second := NewTrace(ts.Add(time.Second), "boom", "12345", "error")
+	return []*Trace{first, second}
+}
+
+func export(t *testing.T, format string, columns []string) string {
+	var buffer bytes.Buffer
+	writer, err := NewExportWriter(format, &buffer, columns)
+	assert.Nil(t, err)
+	for _, trc := range getExportTraces() {
+		assert.Nil(t, writer.Write(trc))
+	}
+	assert.Nil(t, writer.Close())
+	return buffer.String()
+}
+
+func Test_export_ndjson(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(export(t, ExportNdjson, nil)), "\n")
+	assert.Equal(t, 2, len(lines))
+	var trc Trace
+	assert.Nil(t, json.Unmarshal([]byte(lines[1]), &trc))
+	assert.Equal(t, "boom", trc.Message)
+}
+
+func Test_export_csv_with_columns(t *testing.T) {
+	output := export(t, ExportCsv, []string{"Level", "Message", "Properties.user", "Metrics.took"})
+	assert.Equal(t, "Level,Message,Properties.user,Metrics.took\ninfo,\"hello, world\",jo,12\nerror,boom,,\n", output)
+
+	_, err := NewExportWriter(ExportCsv, &bytes.Buffer{}, []string{"Nonsense"})
+	assert.NotNil(t, err)
+}
+
+func Test_export_clef_reads_back(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(export(t, ExportClef, nil)), "\n")
+	trc, err := NewPayloadParser().Parse(lines[0])
+	assert.Nil(t, err)
+	assert.Equal(t, "hello, world", trc.Message)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", trc.CorrelationId)
+	assert.Equal(t, "00f067aa0ba902b7", trc.SpanId)
+	assert.Equal(t, "jo", trc.Properties["user"])
+	assert.Equal(t, 12.0, trc.Metrics["took"])
+	assert.Equal(t, getExportTraces()[0].Timestamp, trc.Timestamp.UTC())
+}
+
+func Test_export_otlp(t *testing.T) {
+	var document struct {
+		ResourceLogs []struct {
+			ScopeLogs []struct {
+				LogRecords []map[string]interface{}
+			}
+		}
+	}
+
+	assert.Nil(t, json.Unmarshal([]byte(export(t, ExportOtlp, nil)), &document))
+	records := document.ResourceLogs[0].ScopeLogs[0].LogRecords
+	assert.Equal(t, 2, len(records))
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", records[0]["traceId"])
+	assert.Equal(t, 17.0, records[1]["severityNumber"])
+	assert.Nil(t, records[1]["traceId"])
+
+	var empty bytes.Buffer
+	writer, _ := NewExportWriter(ExportOtlp, &empty, nil)
+	assert.Nil(t, writer.Close())
+	assert.Nil(t, json.Unmarshal(empty.Bytes(), &document))
+}
@@ -0,0 +1,968 @@
+package tracing
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/exp/slices"
+)
+
+/*
+Expressions are compiled once and evaluated against a trace. Values are numbers, strings, booleans or null.
+
+Fields	Message, Level, CorrelationId, SpanId, ParentSpanId, Source, TraceId, PatternId, Timestamp (epoch ms), Properties.name, Metrics.name, Properties["odd.name"]
+Operators	lowest precedence first: | (x | f(a) is f(x, a)), || or, && and, == != =~ !~, < <= > >=, + -, * / %, ! not -
+Functions	lower upper trim len contains starts_with ends_with regex_match regex_replace number string round floor ceil abs min max coalesce if
+
+Missing properties and metrics are null, arithmetic on null yields null and literal regex patterns are compiled once.
+*/
+type Expression struct {
+	source string
+	root   node
+	fields []string // properties and metrics referenced, e.g. Properties.user
+}
+
+// Assignment is an expression whose result is written to a property or metric, e.g. latency_ms = Metrics.latency_s * 1000
+type Assignment struct {
+	Target     string
+	Metric     bool // target explicitly named Metrics.x
+	Property   bool // target explicitly named Properties.x
+	Expression *Expression
+}
+
+func CompileExpression(source string) (*Expression, error) {
+	p, err := newExpressionParser(source)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected %q at %d", p.peek().text, p.peek().pos)
+	}
+
+	return &Expression{source: source, root: root, fields: p.fields}, nil
+}
+
+func CompileAssignment(source string) (*Assignment, error) {
+	eq := -1
+	for i := 0; i < len(source); i++ {
+		if source[i] == '=' && (i+1 >= len(source) || (source[i+1] != '=' && source[i+1] != '~')) &&
+			(i == 0 || !strings.ContainsRune("=!<>", rune(source[i-1]))) {
+			eq = i
+			break
+		}
+	}
+
+	if eq < 0 {
+		return nil, errors.New("assignment needs a target, e.g. name = expression")
+	}
+
+	assignment := &Assignment{Target: strings.TrimSpace(source[:eq])}
+	if strings.HasPrefix(assignment.Target, "Metrics.") {
+		assignment.Metric = true
+		assignment.Target = strings.TrimPrefix(assignment.Target, "Metrics.")
+	} else if strings.HasPrefix(assignment.Target, "Properties.") {
+		assignment.Property = true
+		assignment.Target = strings.TrimPrefix(assignment.Target, "Properties.")
+	}
+
+	if assignment.Target == "" {
+		return nil, errors.New("assignment target is empty")
+	}
+
+	var err error
+	assignment.Expression, err = CompileExpression(source[eq+1:])
+	if err != nil {
+		return nil, err
+	}
+
+	return assignment, nil
+}
+
+func (expression *Expression) String() string {
+	return expression.source
+}
+
+// Fields returns the properties and metrics the expression refers to, named as Properties.name and Metrics.name
+func (expression *Expression) Fields() []string {
+	return expression.fields
+}
+
+// Evaluate returns a float64, string, bool or nil
+func (expression *Expression) Evaluate(trc *Trace) interface{} {
+	return expression.root.eval(trc)
+}
+
+func (expression *Expression) IsTrue(trc *Trace) bool {
+	return truthy(expression.Evaluate(trc))
+}
+
+// Apply writes numbers to metrics and anything else to properties, unless the target says otherwise
+func (assignment *Assignment) Apply(trc *Trace) {
+	value := assignment.Expression.Evaluate(trc)
+	if value == nil {
+		return
+	}
+
+	f, isNumber := value.(float64)
+	if assignment.Metric || (isNumber && !assignment.Property) {
+		if !isNumber {
+			var ok bool
+			f, ok = toNumber(value)
+			if !ok {
+				return
+			}
+		}
+		trc.Metrics[assignment.Target] = f
+	} else {
+		trc.Properties[assignment.Target] = toString(value)
+	}
+}
+
+// ______________________ LEXER ______________________
+
+const (
+	tokenEnd = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenOperator
+)
+
+type token struct {
+	kind int
+	text string
+	num  float64
+	pos  int
+}
+
+func tokenize(source string) ([]token, error) {
+	tokens := make([]token, 0)
+	i := 0
+	for i < len(source) {
+		c := rune(source[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsDigit(c) || (c == '.' && i+1 < len(source) && unicode.IsDigit(rune(source[i+1]))):
+			start := i
+			for i < len(source) && (unicode.IsDigit(rune(source[i])) || source[i] == '.' || source[i] == 'e' || source[i] == 'E' ||
+				((source[i] == '-' || source[i] == '+') && (source[i-1] == 'e' || source[i-1] == 'E'))) {
+				i++
+			}
+			f, err := strconv.ParseFloat(source[start:i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at %d", source[start:i], start)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: source[start:i], num: f, pos: start})
+		case c == '"' || c == '\'':
+			start := i
+			i++
+			var sb strings.Builder
+			closed := false
+			for i < len(source) {
+				if source[i] == byte(c) {
+					closed = true
+					i++
+					break
+				}
+				if source[i] == '\\' && i+1 < len(source) {
+					switch source[i+1] {
+					case 'n':
+						sb.WriteByte('\n')
+					case 't':
+						sb.WriteByte('\t')
+					case '\\', '"', '\'':
+						sb.WriteByte(source[i+1])
+					default:
+						// kept as is so regular expressions like "\d+" need no double escaping
+						sb.WriteByte('\\')
+						sb.WriteByte(source[i+1])
+					}
+					i += 2
+					continue
+				}
+				sb.WriteByte(source[i])
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated string at %d", start)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: sb.String(), pos: start})
+		case unicode.IsLetter(c) || c == '_' || c == '@':
+			start := i
+			for i < len(source) && (unicode.IsLetter(rune(source[i])) || unicode.IsDigit(rune(source[i])) || source[i] == '_' || source[i] == '@') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: source[start:i], pos: start})
+		default:
+			op := ""
+			for _, candidate := range []string{"||", "&&", "==", "!=", "=~", "!~", "<=", ">="} {
+				if strings.HasPrefix(source[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				if !strings.ContainsRune("+-*/%()[],.|!<>", c) {
+					return nil, fmt.Errorf("unexpected character %q at %d", c, i)
+				}
+				op = string(c)
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: op, pos: i})
+			i += len(op)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEnd, pos: len(source)}), nil
+}
+
+// ______________________ PARSER ______________________
+
+type expressionParser struct {
+	tokens []token
+	pos    int
+	fields []string
+}
+
+func newExpressionParser(source string) (*expressionParser, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+	return &expressionParser{tokens: tokens}, nil
+}
+
+func (p *expressionParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *expressionParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEnd {
+		p.pos++
+	}
+	return t
+}
+
+func (p *expressionParser) done() bool {
+	return p.peek().kind == tokenEnd
+}
+
+// accepts an operator or a keyword alias of it
+func (p *expressionParser) accept(texts ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokenOperator && t.kind != tokenIdent {
+		return "", false
+	}
+	for _, text := range texts {
+		if t.text == text {
+			p.pos++
+			return text, true
+		}
+	}
+	return "", false
+}
+
+func (p *expressionParser) expect(text string) error {
+	if _, ok := p.accept(text); !ok {
+		return fmt.Errorf("expected %q at %d", text, p.peek().pos)
+	}
+	return nil
+}
+
+func (p *expressionParser) parseExpression() (node, error) {
+	left, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if _, ok := p.accept("|"); !ok {
+			return left, nil
+		}
+
+		name := p.next()
+		if name.kind != tokenIdent {
+			return nil, fmt.Errorf("expected function name after | at %d", name.pos)
+		}
+
+		args := []node{left}
+		if _, ok := p.accept("("); ok {
+			rest, err := p.parseArguments()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, rest...)
+		}
+
+		left, err = newCall(name.text, args)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *expressionParser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("||", "or"); !ok {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{and: false, left: left, right: right}
+	}
+}
+
+func (p *expressionParser) parseAnd() (node, error) {
+	left, err := p.parseEquality()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("&&", "and"); !ok {
+			return left, nil
+		}
+		right, err := p.parseEquality()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{and: true, left: left, right: right}
+	}
+}
+
+func (p *expressionParser) parseEquality() (node, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("==", "!=", "=~", "!~")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		if op == "=~" || op == "!~" {
+			left, err = newCall("regex_match", []node{left, right})
+			if err != nil {
+				return nil, err
+			}
+			if op == "!~" {
+				left = &notNode{operand: left}
+			}
+		} else {
+			left = &binaryNode{op: op, left: left, right: right}
+		}
+	}
+}
+
+func (p *expressionParser) parseComparison() (node, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("<", "<=", ">", ">=")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *expressionParser) parseAdditive() (node, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("+", "-")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *expressionParser) parseMultiplicative() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("*", "/", "%")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *expressionParser) parseUnary() (node, error) {
+	if _, ok := p.accept("!", "not"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+
+	if _, ok := p.accept("-"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &binaryNode{op: "-", left: &literalNode{value: 0.0}, right: operand}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *expressionParser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber:
+		return &literalNode{value: t.num}, nil
+	case tokenString:
+		return &literalNode{value: t.text}, nil
+	case tokenOperator:
+		if t.text == "(" {
+			inner, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			return inner, p.expect(")")
+		}
+	case tokenIdent:
+		switch t.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null":
+			return &literalNode{value: nil}, nil
+		case "Properties", "Metrics":
+			return p.parseMapField(t.text)
+		}
+
+		if _, ok := p.accept("("); ok {
+			args, err := p.parseArguments()
+			if err != nil {
+				return nil, err
+			}
+			return newCall(t.text, args)
+		}
+
+		if _, ok := traceFields[t.text]; !ok {
+			return nil, fmt.Errorf("unknown field %q at %d", t.text, t.pos)
+		}
+		return &fieldNode{name: t.text}, nil
+	case tokenEnd:
+		return nil, errors.New("unexpected end of expression")
+	}
+
+	return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+}
+
+func (p *expressionParser) parseMapField(mapName string) (node, error) {
+	var name string
+	if _, ok := p.accept("."); ok {
+		t := p.next()
+		if t.kind != tokenIdent {
+			return nil, fmt.Errorf("expected name after %s. at %d", mapName, t.pos)
+		}
+		name = t.text
+	} else if _, ok := p.accept("["); ok {
+		t := p.next()
+		if t.kind != tokenString {
+			return nil, fmt.Errorf("expected string in %s[] at %d", mapName, t.pos)
+		}
+		name = t.text
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, fmt.Errorf("expected . or [ after %s", mapName)
+	}
+
+	field := mapName + "." + name
+	if !slices.Contains(p.fields, field) {
+		p.fields = append(p.fields, field)
+	}
+	return &mapFieldNode{metric: mapName == "Metrics", name: name}, nil
+}
+
+// parses the arguments of a call after its opening parenthesis
+func (p *expressionParser) parseArguments() ([]node, error) {
+	args := make([]node, 0)
+	if _, ok := p.accept(")"); ok {
+		return args, nil
+	}
+
+	for {
+		arg, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if _, ok := p.accept(")"); ok {
+			return args, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// ______________________ EVALUATION ______________________
+
+type node interface {
+	eval(trc *Trace) interface{}
+}
+
+var traceFields = map[string]func(trc *Trace) interface{}{
+	"Message":       func(trc *Trace) interface{} { return trc.Message },
+	"Level":         func(trc *Trace) interface{} { return trc.Level },
+	"CorrelationId": func(trc *Trace) interface{} { return trc.CorrelationId },
+	"SpanId":        func(trc *Trace) interface{} { return trc.SpanId },
+	"ParentSpanId":  func(trc *Trace) interface{} { return trc.ParentSpanId },
+	"Source":        func(trc *Trace) interface{} { return trc.Source },
+	"TraceId":       func(trc *Trace) interface{} { return trc.TraceId },
+	"PatternId":     func(trc *Trace) interface{} { return trc.PatternId },
+	"Timestamp":     func(trc *Trace) interface{} { return float64(trc.Timestamp.UnixMilli()) },
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n *literalNode) eval(trc *Trace) interface{} {
+	return n.value
+}
+
+type fieldNode struct {
+	name string
+}
+
+func (n *fieldNode) eval(trc *Trace) interface{} {
+	return traceFields[n.name](trc)
+}
+
+type mapFieldNode struct {
+	metric bool
+	name   string
+}
+
+func (n *mapFieldNode) eval(trc *Trace) interface{} {
+	if n.metric {
+		if value, ok := trc.Metrics[n.name]; ok {
+			return value
+		}
+		return nil
+	}
+	if value, ok := trc.Properties[n.name]; ok {
+		return value
+	}
+	return nil
+}
+
+type notNode struct {
+	operand node
+}
+
+func (n *notNode) eval(trc *Trace) interface{} {
+	return !truthy(n.operand.eval(trc))
+}
+
+type logicalNode struct {
+	and         bool
+	left, right node
+}
+
+func (n *logicalNode) eval(trc *Trace) interface{} {
+	left := truthy(n.left.eval(trc))
+	if n.and && !left {
+		return false
+	}
+	if !n.and && left {
+		return true
+	}
+	return truthy(n.right.eval(trc))
+}
+
+type binaryNode struct {
+	op          string
+	left, right node
+}
+
+func (n *binaryNode) eval(trc *Trace) interface{} {
+	left := n.left.eval(trc)
+	right := n.right.eval(trc)
+
+	switch n.op {
+	case "==":
+		return equal(left, right)
+	case "!=":
+		return !equal(left, right)
+	case "<", "<=", ">", ">=":
+		c, ok := compare(left, right)
+		if !ok {
+			return false
+		}
+		switch n.op {
+		case "<":
+			return c < 0
+		case "<=":
+			return c <= 0
+		case ">":
+			return c > 0
+		default:
+			return c >= 0
+		}
+	}
+
+	if left == nil || right == nil {
+		return nil
+	}
+
+	if n.op == "+" {
+		ls, leftIsString := left.(string)
+		rs, rightIsString := right.(string)
+		if leftIsString || rightIsString {
+			if !leftIsString {
+				ls = toString(left)
+			}
+			if !rightIsString {
+				rs = toString(right)
+			}
+			return ls + rs
+		}
+	}
+
+	l, ok := toNumber(left)
+	if !ok {
+		return nil
+	}
+	r, ok := toNumber(right)
+	if !ok {
+		return nil
+	}
+
+	switch n.op {
+	case "+":
+		return l + r
+	case "-":
+		return l - r
+	case "*":
+		return l * r
+	case "/":
+		if r == 0 {
+			return nil
+		}
+		return l / r
+	case "%":
+		if r == 0 {
+			return nil
+		}
+		return math.Mod(l, r)
+	}
+	return nil
+}
+
+type callNode struct {
+	name string
+	args []node
+	fn   func(args []interface{}) interface{}
+}
+
+func (n *callNode) eval(trc *Trace) interface{} {
+	values := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		values[i] = arg.eval(trc)
+	}
+	return n.fn(values)
+}
+
+type function struct {
+	minArgs, maxArgs int // maxArgs < 0 means any number
+	fn               func(args []interface{}) interface{}
+}
+
+var functions = map[string]function{
+	"lower": {1, 1, func(args []interface{}) interface{} { return mapString(args[0], strings.ToLower) }},
+	"upper": {1, 1, func(args []interface{}) interface{} { return mapString(args[0], strings.ToUpper) }},
+	"trim":  {1, 1, func(args []interface{}) interface{} { return mapString(args[0], strings.TrimSpace) }},
+	"len": {1, 1, func(args []interface{}) interface{} {
+		if args[0] == nil {
+			return nil
+		}
+		return float64(len(toString(args[0])))
+	}},
+	"contains": {2, 2, func(args []interface{}) interface{} {
+		return args[0] != nil && strings.Contains(toString(args[0]), toString(args[1]))
+	}},
+	"starts_with": {2, 2, func(args []interface{}) interface{} {
+		return args[0] != nil && strings.HasPrefix(toString(args[0]), toString(args[1]))
+	}},
+	"ends_with": {2, 2, func(args []interface{}) interface{} {
+		return args[0] != nil && strings.HasSuffix(toString(args[0]), toString(args[1]))
+	}},
+	"number": {1, 1, func(args []interface{}) interface{} {
+		f, ok := toNumber(args[0])
+		if !ok {
+			return nil
+		}
+		return f
+	}},
+	"string": {1, 1, func(args []interface{}) interface{} {
+		if args[0] == nil {
+			return nil
+		}
+		return toString(args[0])
+	}},
+	"round": {1, 2, func(args []interface{}) interface{} {
+		f, ok := toNumber(args[0])
+		if !ok {
+			return nil
+		}
+		digits := 0.0
+		if len(args) > 1 {
+			digits, _ = toNumber(args[1])
+		}
+		scale := math.Pow(10, digits)
+		return math.Round(f*scale) / scale
+	}},
+	"floor": {1, 1, func(args []interface{}) interface{} { return mapNumber(args[0], math.Floor) }},
+	"ceil":  {1, 1, func(args []interface{}) interface{} { return mapNumber(args[0], math.Ceil) }},
+	"abs":   {1, 1, func(args []interface{}) interface{} { return mapNumber(args[0], math.Abs) }},
+	"min": {1, -1, func(args []interface{}) interface{} {
+		return foldNumbers(args, math.Min)
+	}},
+	"max": {1, -1, func(args []interface{}) interface{} {
+		return foldNumbers(args, math.Max)
+	}},
+	"coalesce": {1, -1, func(args []interface{}) interface{} {
+		for _, arg := range args {
+			if arg != nil {
+				return arg
+			}
+		}
+		return nil
+	}},
+	"if": {3, 3, func(args []interface{}) interface{} {
+		if truthy(args[0]) {
+			return args[1]
+		}
+		return args[2]
+	}},
+}
+
+func newCall(name string, args []node) (node, error) {
+	switch name {
+	case "regex_match", "regex_replace":
+		return newRegexCall(name, args)
+	}
+
+	f, ok := functions[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q", name)
+	}
+
+	if len(args) < f.minArgs || (f.maxArgs >= 0 && len(args) > f.maxArgs) {
+		return nil, fmt.Errorf("wrong number of arguments for %s", name)
+	}
+
+	return &callNode{name: name, args: args, fn: f.fn}, nil
+}
+
+// regex functions compile literal patterns once and other patterns on every call
+func newRegexCall(name string, args []node) (node, error) {
+	wanted := 2
+	if name == "regex_replace" {
+		wanted = 3
+	}
+	if len(args) != wanted {
+		return nil, fmt.Errorf("wrong number of arguments for %s", name)
+	}
+
+	var compiled *regexp.Regexp
+	if literal, ok := args[1].(*literalNode); ok {
+		pattern, isString := literal.value.(string)
+		if !isString {
+			return nil, fmt.Errorf("pattern of %s must be a string", name)
+		}
+		var err error
+		compiled, err = regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	getRegex := func(pattern interface{}) *regexp.Regexp {
+		if compiled != nil {
+			return compiled
+		}
+		re, err := regexp.Compile(toString(pattern))
+		if err != nil {
+			return nil
+		}
+		return re
+	}
+
+	fn := func(values []interface{}) interface{} {
+		re := getRegex(values[1])
+		if values[0] == nil || re == nil {
+			if name == "regex_match" {
+				return false
+			}
+			return nil
+		}
+		if name == "regex_match" {
+			return re.MatchString(toString(values[0]))
+		}
+		return re.ReplaceAllString(toString(values[0]), toString(values[2]))
+	}
+
+	return &callNode{name: name, args: args, fn: fn}, nil
+}
+
+func truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	}
+	return true
+}
+
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fmt.Sprint(value)
+}
+
+func equal(left, right interface{}) bool {
+	if left == nil || right == nil {
+		return left == nil && right == nil
+	}
+	if c, ok := compare(left, right); ok {
+		return c == 0
+	}
+	return toString(left) == toString(right)
+}
+
+// numbers compare numerically, falling back to comparing as strings
+func compare(left, right interface{}) (int, bool) {
+	if left == nil || right == nil {
+		return 0, false
+	}
+
+	_, leftIsString := left.(string)
+	_, rightIsString := right.(string)
+	if !leftIsString || !rightIsString {
+		l, lok := toNumber(left)
+		r, rok := toNumber(right)
+		if lok && rok {
+			switch {
+			case l < r:
+				return -1, true
+			case l > r:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+
+	return strings.Compare(toString(left), toString(right)), true
+}
+
+func mapString(value interface{}, fn func(string) string) interface{} {
+	if value == nil {
+		return nil
+	}
+	return fn(toString(value))
+}
+
+func mapNumber(value interface{}, fn func(float64) float64) interface{} {
+	f, ok := toNumber(value)
+	if !ok {
+		return nil
+	}
+	return fn(f)
+}
+
+func foldNumbers(args []interface{}, fn func(a, b float64) float64) interface{} {
+	var result interface{}
+	for _, arg := range args {
+		f, ok := toNumber(arg)
+		if !ok {
+			continue
+		}
+		if result == nil {
+			result = f
+		} else {
+			result = fn(result.(float64), f)
+		}
+	}
+	return result
+}
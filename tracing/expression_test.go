@@ -0,0 +1,120 @@
+package tracing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getExpressionTrace() *Trace {
+	trc := NewTrace(time.Now(), "GET /orders/123 done", "12345", "warn")
+	trc.Properties["path"] = "/orders/123/items/45"
+	trc.Properties["http.method"] = "GET"
+	trc.Properties["count"] = "7"
+	trc.Metrics["latency_s"] = 0.25
+	return trc
+}
+
+func evaluate(t *testing.T, source string) interface{} {
+	expression, err := CompileExpression(source)
+	assert.Nil(t, err, source)
+	if err != nil {
+		return nil
+	}
+	return expression.Evaluate(getExpressionTrace())
+}
+
+func Test_expression_arithmetic_and_fields(t *testing.T) {
+	assert.Equal(t, 250.0, evaluate(t, "Metrics.latency_s * 1000"))
+	assert.Equal(t, 7.0, evaluate(t, "1 + 2 * 3"))
+	assert.Equal(t, 9.0, evaluate(t, "(1 + 2) * 3"))
+	assert.Equal(t, -2.0, evaluate(t, "-2"))
+	assert.Equal(t, 1.0, evaluate(t, "10 % 3"))
+	assert.Equal(t, 14.0, evaluate(t, "Properties.count * 2"))
+	assert.Equal(t, "GET", evaluate(t, `Properties["http.method"]`))
+	assert.Equal(t, "warn!", evaluate(t, `Level + "!"`))
+	assert.Nil(t, evaluate(t, "Metrics.missing * 1000"))
+	assert.Nil(t, evaluate(t, "1 / 0"))
+}
+
+func Test_expression_comparisons_and_logic(t *testing.T) {
+	assert.Equal(t, true, evaluate(t, `Level == "warn" && Metrics.latency_s > 0.2`))
+	assert.Equal(t, true, evaluate(t, `Level == "info" or Properties.count >= 7`))
+	assert.Equal(t, false, evaluate(t, `not (Level == "warn")`))
+	assert.Equal(t, true, evaluate(t, `Message =~ "^GET"`))
+	assert.Equal(t, true, evaluate(t, `Message !~ "^POST"`))
+	assert.Equal(t, true, evaluate(t, `Properties.missing == null`))
+	assert.Equal(t, false, evaluate(t, `Metrics.missing > 1`))
+	assert.Equal(t, true, evaluate(t, `"10" > 9`))
+}
+
+func Test_expression_pipes_and_functions(t *testing.T) {
+	assert.Equal(t, "/orders/:id/items/:id", evaluate(t, `Properties.path | regex_replace("/\d+", "/:id")`))
+	assert.Equal(t, "get", evaluate(t, `Properties["http.method"] | lower`))
+	assert.Equal(t, 0.3, evaluate(t, `Metrics.latency_s + 0.04 | round(1)`))
+	assert.Equal(t, "n/a", evaluate(t, `coalesce(Properties.missing, "n/a")`))
+	assert.Equal(t, "slow", evaluate(t, `if(Metrics.latency_s > 0.1, "slow", "fast")`))
+	assert.Equal(t, true, evaluate(t, `contains(Message, "orders")`))
+	assert.Equal(t, 3.0, evaluate(t, `max(1, 3, Metrics.missing)`))
+	assert.Equal(t, 20.0, evaluate(t, `len(Message)`))
+}
+
+func Test_expression_compile_errors(t *testing.T) {
+	for _, source := range []string{
+		"1 +",
+		"Nonsense > 1",
+		"frobnicate(1)",
+		"lower(1, 2)",
+		`regex_match(Message, "((")`,
+		`"unterminated`,
+		"1 2",
+		"Properties.",
+		"a = 1",
+	} {
+		_, err := CompileExpression(source)
+		assert.NotNil(t, err, source)
+	}
+}
+
+func Test_assignment(t *testing.T) {
+	trc := getExpressionTrace()
+
+	assignment, err := CompileAssignment("latency_ms = Metrics.latency_s * 1000")
+	assert.Nil(t, err)
+	assignment.Apply(trc)
+	assert.Equal(t, 250.0, trc.Metrics["latency_ms"])
+
+	assignment, err = CompileAssignment(`route = Properties.path | regex_replace("/\d+", "/:id")`)
+	assert.Nil(t, err)
+	assignment.Apply(trc)
+	assert.Equal(t, "/orders/:id/items/:id", trc.Properties["route"])
+
+	assignment, err = CompileAssignment(`Properties.slow = Metrics.latency_s >= 0.25`)
+	assert.Nil(t, err)
+	assignment.Apply(trc)
+	assert.Equal(t, "true", trc.Properties["slow"])
+
+	_, err = CompileAssignment("Metrics.latency_s * 1000")
+	assert.NotNil(t, err)
+}
+
+func Test_match_with_expression(t *testing.T) {
+	m := Match{Expression: "Metrics.latency_s > 0.1"}
+	assert.Nil(t, m.Compile())
+	assert.True(t, m.IsMatch(getExpressionTrace()))
+
+	m = Match{Expression: "Metrics.latency_s >"}
+	assert.NotNil(t, m.Compile())
+}
+
+func Test_compute_processor(t *testing.T) {
+	processor, err := BuildProcessor(&ProcessorConfig{
+		Type:        ProcessorCompute,
+		Expressions: []string{"latency_ms = Metrics.latency_s * 1000"},
+	})
+	assert.Nil(t, err)
+
+	entries, _ := processor.Process(&Entry{Trace: getExpressionTrace()})
+	assert.Equal(t, 250.0, entries[0].Trace.Metrics["latency_ms"])
+}
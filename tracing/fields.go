@@ -0,0 +1,134 @@
+package tracing
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	FieldProperty = "property"
+	FieldMetric   = "metric"
+
+	max_field_samples  = 5
+	max_field_distinct = 1000 // distinct values tracked per field, cardinality is capped there
+)
+
+type FieldInfo struct {
+	Name              string // as used in queries, Properties.name or Metrics.name
+	Kind              string
+	Count             uint64
+	Cardinality       int
+	CardinalityCapped bool // more distinct values were seen than counted
+	Samples           []string
+	FirstSeen         time.Time
+	LastSeen          time.Time
+}
+
+// FieldCatalog keeps every property and metric seen since the start, as a processor in the pipeline
+type FieldCatalog struct {
+	lock     sync.Mutex
+	fields   map[string]*FieldInfo
+	distinct map[string]map[string]bool
+}
+
+func NewFieldCatalog() *FieldCatalog {
+	return &FieldCatalog{
+		fields:   make(map[string]*FieldInfo),
+		distinct: make(map[string]map[string]bool),
+	}
+}
+
+func (catalog *FieldCatalog) Process(entry *Entry) ([]*Entry, error) {
+	catalog.Record(entry.Trace)
+	return []*Entry{entry}, nil
+}
+
+func (catalog *FieldCatalog) Record(trc *Trace) {
+	catalog.lock.Lock()
+	defer catalog.lock.Unlock()
+	for name, value := range trc.Properties {
+		catalog.record("Properties."+name, FieldProperty, value, trc.Timestamp)
+	}
+	for name, value := range trc.Metrics {
+		catalog.record("Metrics."+name, FieldMetric, strconv.FormatFloat(value, 'f', -1, 64), trc.Timestamp)
+	}
+}
+
+func (catalog *FieldCatalog) record(name, kind, value string, ts time.Time) {
+	field, ok := catalog.fields[name]
+	if !ok {
+		field = &FieldInfo{
+			Name:      name,
+			Kind:      kind,
+			Samples:   make([]string, 0),
+			FirstSeen: ts,
+			LastSeen:  ts,
+		}
+		catalog.fields[name] = field
+		catalog.distinct[name] = make(map[string]bool)
+	}
+
+	field.Count++
+	if ts.Before(field.FirstSeen) {
+		field.FirstSeen = ts
+	}
+	if ts.After(field.LastSeen) {
+		field.LastSeen = ts
+	}
+
+	distinct := catalog.distinct[name]
+	if distinct[value] {
+		return
+	}
+
+	if len(distinct) == max_field_distinct {
+		field.CardinalityCapped = true
+		return
+	}
+
+	distinct[value] = true
+	field.Cardinality++
+	if len(field.Samples) < max_field_samples {
+		field.Samples = append(field.Samples, value)
+	}
+}
+
+// Reset forgets every field, e.g. once the traces are cleared
+func (catalog *FieldCatalog) Reset() {
+	catalog.lock.Lock()
+	defer catalog.lock.Unlock()
+	catalog.fields = make(map[string]*FieldInfo)
+	catalog.distinct = make(map[string]map[string]bool)
+}
+
+// List returns copies of the fields sorted by name
+func (catalog *FieldCatalog) List() []*FieldInfo {
+	catalog.lock.Lock()
+	defer catalog.lock.Unlock()
+	fields := make([]*FieldInfo, 0, len(catalog.fields))
+	for _, field := range catalog.fields {
+		copied := *field
+		copied.Samples = append([]string{}, field.Samples...)
+		fields = append(fields, &copied)
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Name < fields[j].Name
+	})
+	return fields
+}
+
+// Unknown returns the names, as Properties.name or Metrics.name, which have never been seen
+func (catalog *FieldCatalog) Unknown(names []string) []string {
+	catalog.lock.Lock()
+	defer catalog.lock.Unlock()
+	unknown := make([]string, 0)
+	for _, name := range names {
+		if _, ok := catalog.fields[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	return unknown
+}
@@ -0,0 +1,74 @@
+package tracing
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_field_catalog_records_properties_and_metrics(t *testing.T) {
+	catalog := NewFieldCatalog()
+	start := time.Date(2022, 4, 4, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		trc := NewTrace(start.Add(time.Duration(i)*time.Second), "hello", "12345", "info")
+		trc.Properties["user"] = []string{"jo", "al"}[i%2]
+		trc.Metrics["took"] = float64(i)
+		_, err := catalog.Process(&Entry{Trace: trc})
+		assert.Nil(t, err)
+	}
+
+	fields := catalog.List()
+	assert.Equal(t, 2, len(fields))
+	took, user := fields[0], fields[1]
+
+	assert.Equal(t, "Metrics.took", took.Name)
+	assert.Equal(t, FieldMetric, took.Kind)
+	assert.Equal(t, 10, took.Cardinality)
+	assert.Equal(t, []string{"0", "1", "2", "3", "4"}, took.Samples)
+
+	assert.Equal(t, "Properties.user", user.Name)
+	assert.Equal(t, FieldProperty, user.Kind)
+	assert.Equal(t, uint64(10), user.Count)
+	assert.Equal(t, 2, user.Cardinality)
+	assert.Equal(t, start, user.FirstSeen)
+	assert.Equal(t, start.Add(9*time.Second), user.LastSeen)
+}
+
+func Test_field_catalog_caps_cardinality(t *testing.T) {
+	catalog := NewFieldCatalog()
+	for i := 0; i < max_field_distinct+10; i++ {
+		trc := NewTrace(time.Now(), "hello", "12345", "info")
+		trc.Properties["id"] = strconv.Itoa(i)
+		catalog.Record(trc)
+	}
+
+	field := catalog.List()[0]
+	assert.Equal(t, max_field_distinct, field.Cardinality)
+	assert.True(t, field.CardinalityCapped)
+	assert.Equal(t, max_field_samples, len(field.Samples))
+}
+
+func Test_field_catalog_unknown_fields_of_expression(t *testing.T) {
+	catalog := NewFieldCatalog()
+	trc := NewTrace(time.Now(), "hello", "12345", "info")
+	trc.Properties["user"] = "jo"
+	catalog.Record(trc)
+
+	expression, err := CompileExpression(`Properties.user == "jo" && Metrics.took > 10 && Properties["user"] != ""`)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Properties.user", "Metrics.took"}, expression.Fields())
+	assert.Equal(t, []string{"Metrics.took"}, catalog.Unknown(expression.Fields()))
+}
+
+func Test_field_catalog_reset(t *testing.T) {
+	catalog := NewFieldCatalog()
+	trc := NewTrace(time.Now(), "hello", "12345", "info")
+	trc.Properties["user"] = "jo"
+	catalog.Record(trc)
+	catalog.Reset()
+
+	assert.Equal(t, 0, len(catalog.List()))
+	assert.Equal(t, []string{"Properties.user"}, catalog.Unknown([]string{"Properties.user"}))
+}
@@ -0,0 +1,173 @@
+package tracing
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+)
+
+const (
+	FilterInclude = "include"
+	FilterExclude = "exclude"
+)
+
+type FilterRule struct {
+	Id      string
+	Action  string // include or exclude
+	Match   Match
+	Dropped uint64 // traces dropped because of this rule
+}
+
+// FilterRules decides which traces get stored. A trace matching any exclude rule
+// is dropped. If there are include rules, a trace has to match one of them to be kept.
+type FilterRules struct {
+	lock        sync.RWMutex
+	rules       []*FilterRule
+	notIncluded uint64
+}
+
+type FilterRulesSummary struct {
+	Rules       []*FilterRule
+	NotIncluded uint64 // traces dropped for not matching any include rule
+}
+
+func NewFilterRules() *FilterRules {
+	return &FilterRules{
+		rules: make([]*FilterRule, 0),
+	}
+}
+
+// LoadFilterRules reads a JSON array of rules from a file
+func LoadFilterRules(path string) (*FilterRules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []*FilterRule
+	err = json.Unmarshal(data, &rules)
+	if err != nil {
+		return nil, err
+	}
+
+	filters := NewFilterRules()
+	for _, rule := range rules {
+		_, err = filters.Add(rule)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return filters, nil
+}
+
+// Add validates the rule and assigns a new id to it
+func (filters *FilterRules) Add(rule *FilterRule) (*FilterRule, error) {
+	err := validateFilterRule(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	rule.Id = uuid.New().String()
+	rule.Dropped = 0
+	filters.lock.Lock()
+	defer filters.lock.Unlock()
+	filters.rules = append(filters.rules, rule)
+	return rule, nil
+}
+
+// Update replaces the rule with the same id and returns false if there is none
+func (filters *FilterRules) Update(rule *FilterRule) (bool, error) {
+	err := validateFilterRule(rule)
+	if err != nil {
+		return false, err
+	}
+
+	filters.lock.Lock()
+	defer filters.lock.Unlock()
+	for i, existing := range filters.rules {
+		if existing.Id == rule.Id {
+			rule.Dropped = atomic.LoadUint64(&existing.Dropped)
+			filters.rules[i] = rule
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Remove returns false if no rule with the id exists
+func (filters *FilterRules) Remove(id string) bool {
+	filters.lock.Lock()
+	defer filters.lock.Unlock()
+	for i, existing := range filters.rules {
+		if existing.Id == id {
+			filters.rules = append(filters.rules[:i], filters.rules[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+func (filters *FilterRules) Summary() *FilterRulesSummary {
+	filters.lock.RLock()
+	defer filters.lock.RUnlock()
+	summary := &FilterRulesSummary{
+		Rules:       make([]*FilterRule, 0, len(filters.rules)),
+		NotIncluded: atomic.LoadUint64(&filters.notIncluded),
+	}
+
+	for _, rule := range filters.rules {
+		summary.Rules = append(summary.Rules, &FilterRule{
+			Id:      rule.Id,
+			Action:  rule.Action,
+			Match:   rule.Match,
+			Dropped: atomic.LoadUint64(&rule.Dropped),
+		})
+	}
+
+	return summary
+}
+
+// Allow returns false if the trace has to be dropped
+func (filters *FilterRules) Allow(trc *Trace) bool {
+	filters.lock.RLock()
+	defer filters.lock.RUnlock()
+	hasInclude := false
+	included := false
+	for _, rule := range filters.rules {
+		if rule.Action == FilterInclude {
+			hasInclude = true
+			included = included || rule.Match.IsMatch(trc)
+		} else if rule.Match.IsMatch(trc) {
+			atomic.AddUint64(&rule.Dropped, 1)
+			return false
+		}
+	}
+
+	if hasInclude && !included {
+		atomic.AddUint64(&filters.notIncluded, 1)
+		return false
+	}
+
+	return true
+}
+
+func validateFilterRule(rule *FilterRule) error {
+	if rule.Action != FilterInclude && rule.Action != FilterExclude {
+		return errors.New("filter action must be include or exclude")
+	}
+	return rule.Match.Compile()
+}
+
+func (filters *FilterRules) Process(entry *Entry) ([]*Entry, error) {
+	if !filters.Allow(entry.Trace) {
+		return nil, nil
+	}
+	return []*Entry{entry}, nil
+}
@@ -0,0 +1,79 @@
+package tracing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getFilterTrace(message, level string, properties map[string]string) *Trace {
+	trc := NewTrace(time.Now(), message, "12345", level)
+	trc.Source = "127.0.0.1:5000"
+	for name, value := range properties {
+		trc.Properties[name] = value
+	}
+	return trc
+}
+
+func Test_match_all_criteria(t *testing.T) {
+	m := Match{
+		Levels:         []string{"warn", "error"},
+		MessagePattern: "^health",
+		Properties:     map[string]string{"path": "/ping$"},
+		SourcePattern:  "^127\\.0\\.0\\.1",
+	}
+	assert.Nil(t, m.Compile())
+
+	assert.True(t, m.IsMatch(getFilterTrace("healthcheck", "warn", map[string]string{"path": "/api/ping"})))
+	assert.False(t, m.IsMatch(getFilterTrace("healthcheck", "info", map[string]string{"path": "/api/ping"})))
+	assert.False(t, m.IsMatch(getFilterTrace("request", "warn", map[string]string{"path": "/api/ping"})))
+	assert.False(t, m.IsMatch(getFilterTrace("healthcheck", "warn", map[string]string{})))
+}
+
+func Test_match_bad_pattern(t *testing.T) {
+	m := Match{MessagePattern: "(("}
+	assert.NotNil(t, m.Compile())
+}
+
+func Test_exclude_rule_drops_and_counts(t *testing.T) {
+	filters := NewFilterRules()
+	rule, err := filters.Add(&FilterRule{Action: FilterExclude, Match: Match{MessagePattern: "heartbeat"}})
+	assert.Nil(t, err)
+	assert.NotEqual(t, "", rule.Id)
+
+	assert.False(t, filters.Allow(getFilterTrace("heartbeat", "info", nil)))
+	assert.False(t, filters.Allow(getFilterTrace("heartbeat again", "info", nil)))
+	assert.True(t, filters.Allow(getFilterTrace("hello", "info", nil)))
+
+	summary := filters.Summary()
+	assert.Equal(t, uint64(2), summary.Rules[0].Dropped)
+}
+
+func Test_include_rules_drop_everything_else(t *testing.T) {
+	filters := NewFilterRules()
+	_, err := filters.Add(&FilterRule{Action: FilterInclude, Match: Match{Levels: []string{"error"}}})
+	assert.Nil(t, err)
+
+	assert.True(t, filters.Allow(getFilterTrace("boom", "error", nil)))
+	assert.False(t, filters.Allow(getFilterTrace("hello", "info", nil)))
+	assert.Equal(t, uint64(1), filters.Summary().NotIncluded)
+}
+
+func Test_update_and_remove_rules(t *testing.T) {
+	filters := NewFilterRules()
+	rule, _ := filters.Add(&FilterRule{Action: FilterExclude, Match: Match{MessagePattern: "heartbeat"}})
+
+	found, err := filters.Update(&FilterRule{Id: rule.Id, Action: FilterExclude, Match: Match{MessagePattern: "ping"}})
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.True(t, filters.Allow(getFilterTrace("heartbeat", "info", nil)))
+	assert.False(t, filters.Allow(getFilterTrace("ping", "info", nil)))
+
+	_, err = filters.Update(&FilterRule{Id: rule.Id, Action: "ignore"})
+	assert.NotNil(t, err)
+
+	assert.True(t, filters.Remove(rule.Id))
+	assert.False(t, filters.Remove(rule.Id))
+	assert.True(t, filters.Allow(getFilterTrace("ping", "info", nil)))
+}
@@ -0,0 +1,421 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	follow_flush_after = time.Second // a record waiting for more lines is complete once nothing arrives for this long
+	follow_head_size   = 256         // bytes at the start of a file telling it apart from the one it replaced
+	max_follow_record  = 1024 * 1024
+)
+
+type FollowConfig struct {
+	Globs       []string
+	FirstLine   string // pattern of the first line of a record, lines not matching it belong to the record before
+	OffsetsPath string // where the offsets are kept across restarts, nowhere if empty
+	OnError     func(path string, err error)
+}
+
+// FileOffset is how far a file was ingested, along with its start to recognise it after a restart
+type FileOffset struct {
+	Offset int64
+	Head   string
+}
+
+type followedFile struct {
+	path      string
+	file      *os.File
+	info      os.FileInfo
+	head      string
+	offset    int64    // read up to
+	committed int64    // up to the end of the last record ingested
+	partial   []byte   // the start of a line not ended yet
+	record    []string // lines of a record waiting for more
+	size      int      // of the record
+	lastData  time.Time
+}
+
+// FileFollower follows the files matching globs like tail -F does, through rotation and truncation,
+// and ingests every record written to them.
+// Files found at the start are followed from where they were left or else from their end, later ones from their start.
+type FileFollower struct {
+	globs      []string
+	firstLine  *regexp.Regexp
+	config     *FollowConfig
+	parser     *PayloadParser
+	ingest     func(entry *Entry) error
+	flushAfter time.Duration
+	files      map[string]*followedFile
+	offsets    map[string]*FileOffset
+	scanned    bool
+	changed    bool // offsets to save
+	stop       chan struct{}
+	done       sync.WaitGroup
+}
+
+func NewFileFollower(config *FollowConfig, parser *PayloadParser, ingest func(entry *Entry) error) (*FileFollower, error) {
+	if len(config.Globs) == 0 {
+		return nil, errors.New("nothing to follow")
+	}
+
+	for _, glob := range config.Globs {
+		if _, err := filepath.Match(glob, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	follower := &FileFollower{
+		globs:      config.Globs,
+		config:     config,
+		parser:     parser,
+		ingest:     ingest,
+		flushAfter: follow_flush_after,
+		files:      make(map[string]*followedFile),
+		offsets:    make(map[string]*FileOffset),
+	}
+
+	if config.FirstLine != "" {
+		firstLine, err := regexp.Compile(config.FirstLine)
+		if err != nil {
+			return nil, err
+		}
+		follower.firstLine = firstLine
+	}
+
+	if config.OffsetsPath != "" {
+		data, err := os.ReadFile(config.OffsetsPath)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil {
+			err = json.Unmarshal(data, &follower.offsets)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return follower, nil
+}
+
+// Start checks the files for what was written every interval until Stop
+func (follower *FileFollower) Start(interval time.Duration) {
+	follower.stop = make(chan struct{})
+	follower.done.Add(1)
+	go func() {
+		defer follower.done.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			follower.poll(time.Now())
+			select {
+			case <-ticker.C:
+			case <-follower.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop saves the offsets and closes the files. Records still waiting for lines are read again on the next start.
+func (follower *FileFollower) Stop() {
+	if follower.stop != nil {
+		close(follower.stop)
+		follower.done.Wait()
+	}
+
+	follower.saveOffsets()
+	for _, followed := range follower.files {
+		followed.file.Close()
+	}
+	follower.files = make(map[string]*followedFile)
+}
+
+func (follower *FileFollower) report(path string, err error) {
+	if follower.config.OnError != nil {
+		follower.config.OnError(path, err)
+	}
+}
+
+func (follower *FileFollower) poll(now time.Time) {
+	matched := make(map[string]bool)
+	for _, glob := range follower.globs {
+		paths, _ := filepath.Glob(glob)
+		for _, path := range paths {
+			matched[path] = true
+		}
+	}
+
+	paths := make([]string, 0, len(matched))
+	infos := make(map[string]os.FileInfo, len(matched))
+	for path := range matched {
+		info, err := os.Stat(path)
+		if err == nil && !info.IsDir() {
+			paths = append(paths, path)
+			infos[path] = info
+		}
+	}
+	sort.Strings(paths)
+
+	// files are told apart by what they are rather than their path, so one renamed to a name
+	// still matching, like app.log to app.log.1, is read on from where it was left
+	files := make(map[string]*followedFile, len(follower.files))
+	for _, path := range paths {
+		for previous, followed := range follower.files {
+			if os.SameFile(followed.info, infos[path]) {
+				follower.move(followed, path)
+				files[path] = followed
+				delete(follower.files, previous)
+				break
+			}
+		}
+	}
+
+	// removed, or renamed to something not matching
+	for _, followed := range follower.files {
+		follower.drain(followed, now)
+	}
+	follower.files = files
+
+	// what was written to rotated files comes before what the files replacing them have
+	for _, path := range paths {
+		if followed := files[path]; followed != nil {
+			follower.check(followed, infos[path], now)
+		}
+	}
+
+	for _, path := range paths {
+		if files[path] != nil {
+			continue
+		}
+
+		followed, err := follower.open(path, infos[path])
+		if err != nil {
+			follower.report(path, err)
+			continue
+		}
+		files[path] = followed
+		follower.check(followed, infos[path], now)
+	}
+
+	follower.scanned = true
+	follower.saveOffsets()
+}
+
+// moves a followed file to the path it was renamed to, its offset is saved under that path next
+func (follower *FileFollower) move(followed *followedFile, path string) {
+	if followed.path == path {
+		return
+	}
+
+	// the file taking the old path, if any, is not where this one was left
+	delete(follower.offsets, followed.path)
+	followed.path = path
+	follower.changed = true
+}
+
+func (follower *FileFollower) check(followed *followedFile, info os.FileInfo, now time.Time) {
+	followed.info = info
+	if info.Size() < followed.offset {
+		// truncated, starts over once what was read is ingested
+		follower.complete(followed)
+		_, err := followed.file.Seek(0, io.SeekStart)
+		if err != nil {
+			follower.report(followed.path, err)
+			return
+		}
+		followed.offset, followed.committed, followed.head = 0, 0, ""
+	}
+
+	follower.read(followed, now, false)
+}
+
+func (follower *FileFollower) open(path string, info os.FileInfo) (*followedFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	followed := &followedFile{path: path, file: file, info: info}
+	followed.head = readHead(file)
+	start := int64(0)
+	if saved, ok := follower.offsets[path]; ok {
+		// a file which replaced the one left is read from its start
+		if saved.Offset <= info.Size() && strings.HasPrefix(followed.head, saved.Head) {
+			start = saved.Offset
+		}
+	} else if !follower.scanned {
+		start = info.Size()
+	}
+
+	_, err = file.Seek(start, io.SeekStart)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	followed.offset, followed.committed = start, start
+	return followed, nil
+}
+
+func readHead(file *os.File) string {
+	head := make([]byte, follow_head_size)
+	n, _ := file.ReadAt(head, 0)
+	return string(head[:n])
+}
+
+// reads the file to its end, then closes it as a rotated or removed file gets no more
+func (follower *FileFollower) drain(followed *followedFile, now time.Time) {
+	follower.read(followed, now, true)
+	followed.file.Close()
+	delete(follower.offsets, followed.path)
+	follower.changed = true
+}
+
+// reads what was added and ingests the records completed, all of them when final
+func (follower *FileFollower) read(followed *followedFile, now time.Time, final bool) {
+	buffer := make([]byte, 64*1024)
+	for {
+		n, err := followed.file.Read(buffer)
+		if n > 0 {
+			followed.offset += int64(n)
+			followed.lastData = now
+			follower.consume(followed, buffer[:n])
+		}
+		if err != nil {
+			if err != io.EOF {
+				follower.report(followed.path, err)
+			}
+			break
+		}
+	}
+
+	if len(followed.head) < follow_head_size {
+		followed.head = readHead(followed.file)
+	}
+
+	if final || now.Sub(followed.lastData) >= follower.flushAfter {
+		follower.complete(followed)
+	}
+}
+
+// ingests everything read, including the last line even if it does not end with a line break
+func (follower *FileFollower) complete(followed *followedFile) {
+	if len(followed.partial) > 0 {
+		follower.addLine(followed, string(followed.partial), followed.offset-int64(len(followed.partial)), followed.offset)
+		followed.partial = nil
+	}
+	follower.flush(followed)
+	followed.committed = followed.offset
+}
+
+func (follower *FileFollower) consume(followed *followedFile, data []byte) {
+	followed.partial = append(followed.partial, data...)
+	start := followed.offset - int64(len(followed.partial))
+	for {
+		end := bytes.IndexByte(followed.partial, '\n')
+		if end < 0 {
+			if len(followed.partial) < max_follow_record {
+				return
+			}
+			end = len(followed.partial) - 1 // too long to wait for its end
+		}
+
+		line := strings.TrimRight(string(followed.partial[:end+1]), "\r\n")
+		followed.partial = followed.partial[end+1:]
+		follower.addLine(followed, line, start, start+int64(end+1))
+		start += int64(end + 1)
+	}
+}
+
+// adds the line, which is between the offsets, to the record or ingests it as one
+func (follower *FileFollower) addLine(followed *followedFile, line string, start int64, end int64) {
+	if follower.firstLine == nil {
+		follower.ingestRecord(followed, line)
+		followed.committed = end
+		return
+	}
+
+	if len(followed.record) > 0 && (follower.firstLine.MatchString(line) || followed.size+len(line) > max_follow_record) {
+		follower.flush(followed)
+		followed.committed = start
+	}
+
+	followed.record = append(followed.record, line)
+	followed.size += len(line) + 1
+}
+
+func (follower *FileFollower) flush(followed *followedFile) {
+	if len(followed.record) == 0 {
+		return
+	}
+
+	record := strings.Join(followed.record, "\n")
+	followed.record, followed.size = nil, 0
+	follower.ingestRecord(followed, record)
+}
+
+func (follower *FileFollower) ingestRecord(followed *followedFile, record string) {
+	payload := strings.TrimSpace(record)
+	if payload == "" {
+		return
+	}
+
+	if !strings.HasPrefix(payload, "{") {
+		payload = textToClef(payload)
+	}
+
+	trc, err := follower.parser.Parse(payload)
+	if err == nil {
+		trc.Source = "file:" + followed.path
+		// namespaced so a path the record has, like that of a request, is kept
+		trc.Properties["file.path"] = followed.path
+		err = follower.ingest(&Entry{Trace: trc, Payload: record})
+	}
+
+	if err != nil {
+		follower.report(followed.path, err)
+	}
+}
+
+func (follower *FileFollower) saveOffsets() {
+	for path, followed := range follower.files {
+		if saved, ok := follower.offsets[path]; !ok || saved.Offset != followed.committed || saved.Head != followed.head {
+			follower.offsets[path] = &FileOffset{Offset: followed.committed, Head: followed.head}
+			follower.changed = true
+		}
+	}
+
+	if !follower.changed || follower.config.OffsetsPath == "" {
+		return
+	}
+
+	data, err := json.MarshalIndent(follower.offsets, "", "  ")
+	if err != nil {
+		follower.report(follower.config.OffsetsPath, err)
+		return
+	}
+
+	// written aside and renamed over, so a crash does not leave half the offsets
+	temporary := follower.config.OffsetsPath + ".tmp"
+	err = os.WriteFile(temporary, data, 0644)
+	if err == nil {
+		err = os.Rename(temporary, follower.config.OffsetsPath)
+	}
+	if err != nil {
+		follower.report(follower.config.OffsetsPath, err)
+		return
+	}
+	follower.changed = false
+}
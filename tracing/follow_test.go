@@ -0,0 +1,149 @@
+package tracing
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func followInto(t *testing.T, config *FollowConfig) (*FileFollower, *[]*Trace) {
+	traces := make([]*Trace, 0)
+	follower, err := NewFileFollower(config, NewPayloadParser(), func(entry *Entry) error {
+		traces = append(traces, entry.Trace)
+		return nil
+	})
+	assert.Nil(t, err)
+	follower.flushAfter = 0
+	return follower, &traces
+}
+
+func appendTo(t *testing.T, path string, data string) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	assert.Nil(t, err)
+	_, err = file.WriteString(data)
+	assert.Nil(t, err)
+	assert.Nil(t, file.Close())
+}
+
+func messages(traces []*Trace) []string {
+	result := make([]string, 0, len(traces))
+	for _, trc := range traces {
+		result = append(result, trc.Message)
+	}
+	return result
+}
+
+func Test_follow_starts_at_the_end_of_existing_files(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendTo(t, path, "before\n")
+	follower, traces := followInto(t, &FollowConfig{Globs: []string{filepath.Join(dir, "*.log")}})
+
+	follower.poll(time.Now())
+	appendTo(t, path, "one\n{\"message\":\"two\",\"level\":\"error\",\"path\":\"/orders\"}\n")
+	appendTo(t, filepath.Join(dir, "new.log"), "three\n")
+	follower.poll(time.Now())
+
+	assert.Equal(t, []string{"one", "two", "three"}, messages(*traces))
+	assert.Equal(t, "error", (*traces)[1].Level)
+	assert.Equal(t, "/orders", (*traces)[1].Properties["path"])
+	assert.Equal(t, path, (*traces)[0].Properties["file.path"])
+	assert.Equal(t, "file:"+path, (*traces)[0].Source)
+}
+
+func Test_follow_reassembles_multi_line_records(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	follower, traces := followInto(t, &FollowConfig{Globs: []string{path}, FirstLine: `^\d{4}-`})
+	follower.flushAfter = time.Hour
+
+	follower.poll(time.Now())
+	appendTo(t, path, "2022-04-01T10:00:00Z ERROR boom\n  at a\n  at b\n2022-04-01T10:00:01Z INFO next\n")
+	follower.poll(time.Now())
+
+	assert.Equal(t, []string{"boom\n  at a\n  at b"}, messages(*traces))
+	assert.Equal(t, "error", (*traces)[0].Level)
+	assert.Equal(t, time.Date(2022, 4, 1, 10, 0, 0, 0, time.UTC), (*traces)[0].Timestamp.UTC())
+
+	// the last record is complete once nothing more arrives
+	follower.flushAfter = 0
+	follower.poll(time.Now())
+	assert.Equal(t, []string{"boom\n  at a\n  at b", "next"}, messages(*traces))
+}
+
+func Test_follow_through_rotation_and_truncation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	follower, traces := followInto(t, &FollowConfig{Globs: []string{path}})
+
+	follower.poll(time.Now())
+	appendTo(t, path, "one\n")
+	follower.poll(time.Now())
+	appendTo(t, path, "two\n")
+	assert.Nil(t, os.Rename(path, path+".1"))
+	appendTo(t, path, "three\n")
+	follower.poll(time.Now())
+	assert.Equal(t, []string{"one", "two", "three"}, messages(*traces))
+
+	assert.Nil(t, os.WriteFile(path, []byte("four\n"), 0644))
+	follower.poll(time.Now())
+	assert.Equal(t, []string{"one", "two", "three", "four"}, messages(*traces))
+}
+
+func Test_follow_resumes_from_saved_offsets(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	config := &FollowConfig{Globs: []string{path}, OffsetsPath: filepath.Join(dir, "offsets.json")}
+	follower, traces := followInto(t, config)
+
+	follower.poll(time.Now())
+	appendTo(t, path, "one\n")
+	follower.poll(time.Now())
+	follower.Stop()
+	appendTo(t, path, "two\n")
+
+	follower, traces = followInto(t, config)
+	follower.poll(time.Now())
+	assert.Equal(t, []string{"two"}, messages(*traces))
+	follower.Stop()
+
+	// replaced while stopped, so read from its start
+	assert.Nil(t, os.WriteFile(path, []byte("other\n"), 0644))
+	follower, traces = followInto(t, config)
+	follower.poll(time.Now())
+	assert.Equal(t, []string{"other"}, messages(*traces))
+}
+
+func Test_follow_files_renamed_to_names_still_matching(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	config := &FollowConfig{Globs: []string{path + "*"}, OffsetsPath: filepath.Join(dir, "offsets.json")}
+	follower, traces := followInto(t, config)
+
+	follower.poll(time.Now())
+	appendTo(t, path, "one\n")
+	follower.poll(time.Now())
+	appendTo(t, path, "two\n")
+	assert.Nil(t, os.Rename(path, path+".1"))
+	appendTo(t, path, "three\n")
+	follower.poll(time.Now())
+	follower.poll(time.Now())
+	assert.Equal(t, []string{"one", "two", "three"}, messages(*traces))
+	assert.Equal(t, path+".1", (*traces)[1].Properties["file.path"])
+
+	// rotated once more, the oldest keeps its place as well
+	appendTo(t, path+".1", "four\n")
+	assert.Nil(t, os.Rename(path+".1", path+".2"))
+	assert.Nil(t, os.Rename(path, path+".1"))
+	appendTo(t, path, "five\n")
+	follower.poll(time.Now())
+	assert.Equal(t, []string{"one", "two", "three", "four", "five"}, messages(*traces))
+	follower.Stop()
+
+	follower, traces = followInto(t, config)
+	follower.poll(time.Now())
+	assert.Equal(t, 0, len(*traces))
+}
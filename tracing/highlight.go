@@ -0,0 +1,140 @@
+package tracing
+
+import (
+	"errors"
+	"regexp"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// a hex colour such as #f80 or #ffcc0080, or a named one such as gold, as the UI puts it into markup
+var highlightColor = regexp.MustCompile(`^(?:#(?:[0-9a-fA-F]{3,4}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})|[a-zA-Z]+)$`)
+
+type HighlightRule struct {
+	Id    string
+	Name  string // tag shown next to matching traces
+	Color string
+	Match Match
+}
+
+// Highlighter tags incoming traces with the ids of the highlight rules they match.
+// Rules are persisted in the store and cached here.
+type Highlighter struct {
+	lock  sync.RWMutex
+	store TraceStore
+	rules []*HighlightRule
+}
+
+func NewHighlighter(store TraceStore) (*Highlighter, error) {
+	rules, err := store.ListHighlightRules()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rule := range rules {
+		err = validateHighlightRule(rule)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Highlighter{
+		store: store,
+		rules: rules,
+	}, nil
+}
+
+// Add validates the rule, assigns a new id to it and persists it
+func (highlighter *Highlighter) Add(rule *HighlightRule) (*HighlightRule, error) {
+	err := validateHighlightRule(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	rule.Id = uuid.New().String()
+	highlighter.lock.Lock()
+	defer highlighter.lock.Unlock()
+	err = highlighter.store.SaveHighlightRule(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	highlighter.rules = append(highlighter.rules, rule)
+	return rule, nil
+}
+
+// Update replaces the rule with the same id and returns false if there is none
+func (highlighter *Highlighter) Update(rule *HighlightRule) (bool, error) {
+	err := validateHighlightRule(rule)
+	if err != nil {
+		return false, err
+	}
+
+	highlighter.lock.Lock()
+	defer highlighter.lock.Unlock()
+	for i, existing := range highlighter.rules {
+		if existing.Id == rule.Id {
+			err = highlighter.store.SaveHighlightRule(rule)
+			if err != nil {
+				return false, err
+			}
+			highlighter.rules[i] = rule
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Remove returns false if no rule with the id exists
+func (highlighter *Highlighter) Remove(id string) (bool, error) {
+	highlighter.lock.Lock()
+	defer highlighter.lock.Unlock()
+	for i, existing := range highlighter.rules {
+		if existing.Id == id {
+			_, err := highlighter.store.DeleteHighlightRule(id)
+			if err != nil {
+				return false, err
+			}
+			highlighter.rules = append(highlighter.rules[:i], highlighter.rules[i+1:]...)
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (highlighter *Highlighter) List() []*HighlightRule {
+	highlighter.lock.RLock()
+	defer highlighter.lock.RUnlock()
+	rules := make([]*HighlightRule, len(highlighter.rules))
+	copy(rules, highlighter.rules)
+	return rules
+}
+
+// Apply records the ids of all matching rules on the trace
+func (highlighter *Highlighter) Apply(trc *Trace) {
+	highlighter.lock.RLock()
+	defer highlighter.lock.RUnlock()
+	for _, rule := range highlighter.rules {
+		if rule.Match.IsMatch(trc) {
+			trc.Highlights = append(trc.Highlights, rule.Id)
+		}
+	}
+}
+
+func validateHighlightRule(rule *HighlightRule) error {
+	if rule.Name == "" && rule.Color == "" {
+		return errors.New("highlight rule needs a name or a color")
+	}
+	if rule.Color != "" && !highlightColor.MatchString(rule.Color) {
+		return errors.New("highlight color must be a hex or named color")
+	}
+	return rule.Match.Compile()
+}
+
+func (highlighter *Highlighter) Process(entry *Entry) ([]*Entry, error) {
+	highlighter.Apply(entry.Trace)
+	return []*Entry{entry}, nil
+}
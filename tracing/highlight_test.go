@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_highlight_tags_matching_traces(t *testing.T) {
+	store, _ := NewInMemoryStore(EmptyConfig())
+	highlighter, err := NewHighlighter(store)
+	assert.Nil(t, err)
+
+	errors, err := highlighter.Add(&HighlightRule{Name: "errors", Color: "red", Match: Match{Levels: []string{"error"}}})
+	assert.Nil(t, err)
+	_, err = highlighter.Add(&HighlightRule{Name: "payments", Match: Match{MessagePattern: "payment"}})
+	assert.Nil(t, err)
+
+	trc := NewTrace(time.Now(), "payment failed", "12345", "error")
+	highlighter.Apply(trc)
+	assert.Equal(t, 2, len(trc.Highlights))
+	assert.Equal(t, errors.Id, trc.Highlights[0])
+
+	trc = NewTrace(time.Now(), "hello", "12345", "info")
+	highlighter.Apply(trc)
+	assert.Equal(t, 0, len(trc.Highlights))
+}
+
+func Test_highlight_rules_are_persisted_in_store(t *testing.T) {
+	store, _ := NewInMemoryStore(EmptyConfig())
+	highlighter, _ := NewHighlighter(store)
+	rule, _ := highlighter.Add(&HighlightRule{Name: "errors", Match: Match{Levels: []string{"error"}}})
+
+	reloaded, err := NewHighlighter(store)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(reloaded.List()))
+
+	found, err := reloaded.Update(&HighlightRule{Id: rule.Id, Name: "warnings", Match: Match{Levels: []string{"warn"}}})
+	assert.Nil(t, err)
+	assert.True(t, found)
+	rules, _ := store.ListHighlightRules()
+	assert.Equal(t, "warnings", rules[0].Name)
+
+	removed, err := reloaded.Remove(rule.Id)
+	assert.Nil(t, err)
+	assert.True(t, removed)
+	rules, _ = store.ListHighlightRules()
+	assert.Equal(t, 0, len(rules))
+}
+
+func Test_highlight_rule_needs_name_or_color(t *testing.T) {
+	store, _ := NewInMemoryStore(EmptyConfig())
+	highlighter, _ := NewHighlighter(store)
+	_, err := highlighter.Add(&HighlightRule{Match: Match{Levels: []string{"error"}}})
+	assert.NotNil(t, err)
+}
+
+func Test_highlight_rule_color_is_validated(t *testing.T) {
+	store, _ := NewInMemoryStore(EmptyConfig())
+	highlighter, _ := NewHighlighter(store)
+	for _, color := range []string{"red", "#f80", "#ffcc00", "#ffcc0080"} {
+		_, err := highlighter.Add(&HighlightRule{Color: color})
+		assert.Nil(t, err, color)
+	}
+
+	for _, color := range []string{`red"><script>alert(1)</script>`, "#ff", "rgb(1,2,3)", "red;"} {
+		_, err := highlighter.Add(&HighlightRule{Color: color})
+		assert.NotNil(t, err, color)
+	}
+}
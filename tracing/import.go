@@ -0,0 +1,307 @@
+package tracing
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ImportAuto = "auto" // detected from the first line
+	ImportText = "text" // plain log lines, optionally starting with a timestamp and a level
+)
+
+// lines longer than this are cut by the scanner
+const max_import_line = 1024 * 1024
+
+var textLevels = map[string]string{
+	"TRACE": "trace", "VERBOSE": "verbose", "DEBUG": "debug", "INFO": "info", "INFORMATION": "info",
+	"WARN": "warn", "WARNING": "warn", "ERROR": "error", "FATAL": "fatal", "CRITICAL": "critical",
+}
+
+type ImportResult struct {
+	SessionId string
+	Imported  int
+	Failed    int // lines or records that could not be parsed or ingested
+}
+
+// Import reads an export or a log file and ingests every record through the parser into the session.
+// Records are turned into CLEF payloads where needed so their original timestamps are kept.
+func Import(format string, r io.Reader, parser *PayloadParser, ingest func(entry *Entry) error, source string, sessionId string) (*ImportResult, error) {
+	result := &ImportResult{SessionId: sessionId}
+	reader := bufio.NewReaderSize(r, 64*1024)
+	if format == "" || format == ImportAuto {
+		format = detectImportFormat(reader)
+	}
+
+	handle := func(payload string) {
+		payload = strings.TrimSpace(payload)
+		if payload == "" {
+			return
+		}
+
+		trc, err := parser.Parse(payload)
+		if err == nil {
+			trc.Source = source
+			trc.SessionId = sessionId
+			err = ingest(&Entry{Trace: trc, Payload: payload})
+		}
+
+		if err != nil {
+			result.Failed++
+		} else {
+			result.Imported++
+		}
+	}
+
+	switch format {
+	case ExportOtlp:
+		payloads, err := readOtlp(reader)
+		if err != nil {
+			return nil, err
+		}
+		for _, payload := range payloads {
+			handle(payload)
+		}
+	case ExportNdjson, ExportClef, ImportText:
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 64*1024), max_import_line)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if format == ImportText {
+				line = textToClef(line)
+			} else {
+				line = exportedTraceToClef(line)
+			}
+			handle(line)
+		}
+		if err := scanner.Err(); err != nil {
+			return result, err
+		}
+	default:
+		return nil, CheckImportFormat(format)
+	}
+
+	return result, nil
+}
+
+// CheckImportFormat returns an error unless Import reads the format
+func CheckImportFormat(format string) error {
+	switch format {
+	case "", ImportAuto, ExportOtlp, ExportNdjson, ExportClef, ImportText:
+		return nil
+	}
+
+	return fmt.Errorf("unknown import format %q", format)
+}
+
+func detectImportFormat(reader *bufio.Reader) string {
+	head, _ := reader.Peek(256)
+	trimmed := strings.TrimSpace(string(head))
+	if strings.HasPrefix(trimmed, "{") {
+		if strings.Contains(trimmed, `"resourceLogs"`) {
+			return ExportOtlp
+		}
+		return ExportNdjson
+	}
+	return ImportText
+}
+
+// lines of an NDJSON export are traces and are turned into CLEF, other JSON lines are left as they are
+func exportedTraceToClef(line string) string {
+	if !strings.Contains(line, `"TraceId"`) || !strings.Contains(line, `"TimeIndex"`) {
+		return line
+	}
+
+	var trc Trace
+	if json.Unmarshal([]byte(line), &trc) != nil {
+		return line
+	}
+
+	data, err := json.Marshal(ToClef(&trc))
+	if err != nil {
+		return line
+	}
+	return string(data)
+}
+
+// layouts of the timestamps log lines commonly start with, besides the ones parseDate knows.
+// Those without a zone are in local time.
+var textTimestampLayouts = []string{
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05,999999999",
+	"2006/01/02 15:04:05.999999999",
+}
+
+func parseTextTimestamp(s string) (time.Time, bool) {
+	s = strings.Trim(s, "[]")
+	if timestamp, err := parseDate(s); err == nil {
+		return timestamp, true
+	}
+
+	for _, layout := range textTimestampLayouts {
+		if timestamp, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return timestamp, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// a line starting with a timestamp, in one or two fields, keeps it, optionally followed by a level such as INFO or [warn]
+func textToClef(line string) string {
+	line = strings.TrimSpace(line)
+	fields := strings.SplitN(line, " ", 3)
+	if len(fields) < 2 {
+		return line
+	}
+
+	var timestamp time.Time
+	var rest string
+	parsed := false
+	if len(fields) == 3 {
+		timestamp, parsed = parseTextTimestamp(fields[0] + " " + fields[1])
+		rest = fields[2]
+	}
+	if !parsed {
+		timestamp, parsed = parseTextTimestamp(fields[0])
+		rest = strings.TrimPrefix(line, fields[0])
+	}
+	if !parsed {
+		return line
+	}
+
+	rest = strings.TrimSpace(rest)
+	event := map[string]interface{}{
+		"@t": timestamp.Format(time.RFC3339Nano),
+		"@m": rest,
+	}
+
+	levelAndMessage := strings.SplitN(rest, " ", 2)
+	if level, ok := textLevels[strings.ToUpper(strings.Trim(levelAndMessage[0], "[]:"))]; ok {
+		event["@l"] = level
+		event["@m"] = ""
+		if len(levelAndMessage) > 1 {
+			event["@m"] = strings.TrimSpace(levelAndMessage[1])
+		}
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return line
+	}
+	return string(data)
+}
+
+type otlpImportValue struct {
+	StringValue *string      `json:"stringValue"`
+	DoubleValue *float64     `json:"doubleValue"`
+	IntValue    *json.Number `json:"intValue"`
+	BoolValue   *bool        `json:"boolValue"`
+}
+
+type otlpImportDocument struct {
+	ResourceLogs []struct {
+		ScopeLogs []struct {
+			LogRecords []struct {
+				TimeUnixNano         string          `json:"timeUnixNano"`
+				ObservedTimeUnixNano string          `json:"observedTimeUnixNano"`
+				SeverityText         string          `json:"severityText"`
+				SeverityNumber       int             `json:"severityNumber"`
+				Body                 otlpImportValue `json:"body"`
+				TraceId              string          `json:"traceId"`
+				SpanId               string          `json:"spanId"`
+				Attributes           []struct {
+					Key   string          `json:"key"`
+					Value otlpImportValue `json:"value"`
+				} `json:"attributes"`
+			} `json:"logRecords"`
+		} `json:"scopeLogs"`
+	} `json:"resourceLogs"`
+}
+
+// turns every OTLP log record into a CLEF payload, reversing what the OTLP export does
+func readOtlp(reader io.Reader) ([]string, error) {
+	var document otlpImportDocument
+	err := json.NewDecoder(reader).Decode(&document)
+	if err != nil {
+		return nil, err
+	}
+
+	payloads := make([]string, 0)
+	for _, resourceLogs := range document.ResourceLogs {
+		for _, scopeLogs := range resourceLogs.ScopeLogs {
+			for _, record := range scopeLogs.LogRecords {
+				event := make(map[string]interface{})
+				for _, attribute := range record.Attributes {
+					if value := attribute.Value.toValue(); value != nil {
+						event[attribute.Key] = value
+					}
+				}
+
+				renameKey(event, "correlation.id", "@tr")
+				renameKey(event, "span.id", "@sp")
+				renameKey(event, "parent.span.id", "@ps")
+
+				nanos := record.TimeUnixNano
+				if nanos == "" || nanos == "0" {
+					nanos = record.ObservedTimeUnixNano
+				}
+				n, err := strconv.ParseInt(nanos, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid timeUnixNano %q", nanos)
+				}
+
+				event["@t"] = time.Unix(0, n).UTC().Format(time.RFC3339Nano)
+				event["@m"] = toString(record.Body.toValue())
+				if record.SeverityText != "" {
+					event["@l"] = record.SeverityText
+				}
+				if record.TraceId != "" {
+					event["@tr"] = record.TraceId
+				}
+				if record.SpanId != "" {
+					event["@sp"] = record.SpanId
+				}
+
+				data, err := json.Marshal(event)
+				if err != nil {
+					return nil, err
+				}
+				payloads = append(payloads, string(data))
+			}
+		}
+	}
+
+	return payloads, nil
+}
+
+func (value otlpImportValue) toValue() interface{} {
+	switch {
+	case value.StringValue != nil:
+		return *value.StringValue
+	case value.DoubleValue != nil:
+		return *value.DoubleValue
+	case value.IntValue != nil:
+		f, err := value.IntValue.Float64()
+		if err != nil {
+			return nil
+		}
+		return f
+	case value.BoolValue != nil:
+		return strconv.FormatBool(*value.BoolValue)
+	}
+	return nil
+}
+
+func renameKey(event map[string]interface{}, from string, to string) {
+	if value, ok := event[from]; ok {
+		delete(event, from)
+		event[to] = value
+	}
+}
@@ -0,0 +1,88 @@
+package tracing
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func importInto(t *testing.T, format string, data string) ([]*Trace, *ImportResult) {
+	traces := make([]*Trace, 0)
+	result, err := Import(format, strings.NewReader(data), NewPayloadParser(), func(entry *Entry) error {
+		traces = append(traces, entry.Trace)
+		return nil
+	}, "import:test", "session")
+	assert.Nil(t, err)
+	return traces, result
+}
+
+func Test_import_round_trips_exports(t *testing.T) {
+	for _, format := range []string{ExportNdjson, ExportClef, ExportOtlp} {
+		var buffer bytes.Buffer
+		writer, _ := NewExportWriter(format, &buffer, nil)
+		for _, trc := range getExportTraces() {
+			_ = writer.Write(trc)
+		}
+		_ = writer.Close()
+
+		traces, result := importInto(t, ImportAuto, buffer.String())
+		assert.Equal(t, 2, result.Imported, format)
+		assert.Equal(t, 0, result.Failed, format)
+
+		original := getExportTraces()[0]
+		assert.Equal(t, original.Timestamp, traces[0].Timestamp.UTC(), format)
+		assert.Equal(t, original.Message, traces[0].Message, format)
+		assert.Equal(t, original.CorrelationId, traces[0].CorrelationId, format)
+		assert.Equal(t, original.SpanId, traces[0].SpanId, format)
+		assert.Equal(t, "jo", traces[0].Properties["user"], format)
+		assert.Equal(t, 12.0, traces[0].Metrics["took"], format)
+		assert.Equal(t, "error", traces[1].Level, format)
+		assert.Equal(t, "session", traces[1].SessionId, format)
+		assert.Equal(t, "import:test", traces[1].Source, format)
+	}
+}
+
+func Test_import_text_keeps_timestamps_and_levels(t *testing.T) {
+	traces, result := importInto(t, ImportAuto, "2022-04-04T10:00:00Z [WARN] disk almost full\nno timestamp here\n\n2022-04-04T10:00:01Z started\n")
+	assert.Equal(t, 3, result.Imported)
+
+	assert.Equal(t, "disk almost full", traces[0].Message)
+	assert.Equal(t, "warn", traces[0].Level)
+	assert.Equal(t, time.Date(2022, 4, 4, 10, 0, 0, 0, time.UTC), traces[0].Timestamp.UTC())
+	assert.Equal(t, "no timestamp here", traces[1].Message)
+	assert.Equal(t, "started", traces[2].Message)
+	assert.Equal(t, "info", traces[2].Level)
+}
+
+func Test_import_text_keeps_common_timestamp_layouts(t *testing.T) {
+	traces, result := importInto(t, ImportText, "2022-04-04 10:00:00,123 ERROR boom\n[2022-04-04 10:00:01] started\n2022-04-04T10:00:02 INFO no zone\n2022/04/04 10:00:03 go log\n")
+	assert.Equal(t, 4, result.Imported)
+
+	assert.Equal(t, "boom", traces[0].Message)
+	assert.Equal(t, "error", traces[0].Level)
+	assert.Equal(t, time.Date(2022, 4, 4, 10, 0, 0, 123000000, time.Local), traces[0].Timestamp.Local())
+	assert.Equal(t, "started", traces[1].Message)
+	assert.Equal(t, time.Date(2022, 4, 4, 10, 0, 1, 0, time.Local), traces[1].Timestamp.Local())
+	assert.Equal(t, "no zone", traces[2].Message)
+	assert.Equal(t, time.Date(2022, 4, 4, 10, 0, 2, 0, time.Local), traces[2].Timestamp.Local())
+	assert.Equal(t, "go log", traces[3].Message)
+	assert.Equal(t, time.Date(2022, 4, 4, 10, 0, 3, 0, time.Local), traces[3].Timestamp.Local())
+}
+
+func Test_import_counts_failures(t *testing.T) {
+	_, result := importInto(t, ExportNdjson, "{\"message\":\"ok\"}\n{broken\n")
+	assert.Equal(t, 1, result.Imported)
+	assert.Equal(t, 1, result.Failed)
+
+	_, err := Import("xml", strings.NewReader(""), NewPayloadParser(), nil, "", "")
+	assert.NotNil(t, err)
+}
+
+func Test_import_format_is_checked(t *testing.T) {
+	assert.Nil(t, CheckImportFormat(""))
+	assert.Nil(t, CheckImportFormat(ImportText))
+	assert.NotNil(t, CheckImportFormat("bogus"))
+}
@@ -0,0 +1,143 @@
+package tracing
+
+import (
+	"errors"
+	"sync"
+)
+
+const (
+	PauseBuffer = "buffer" // keeps incoming entries and ingests them on resume
+	PauseDrop   = "drop"   // discards incoming entries
+
+	max_paused_buffer = 10000
+)
+
+// Ingester runs entries through the pipeline into the store and can pause capturing
+type Ingester struct {
+	lock      sync.Mutex
+	store     TraceStore
+	pipeline  *Pipeline
+	imports   *Pipeline
+	pauseMode string // empty while capturing
+	resuming  bool   // replaying the buffer, live entries queue behind it
+	buffer    []*Entry
+	received  uint64
+	dropped   uint64
+}
+
+type CaptureStatus struct {
+	Paused   bool
+	Mode     string
+	Received uint64
+	Buffered int
+	Dropped  uint64 // entries discarded while paused
+}
+
+func NewIngester(store TraceStore, pipeline *Pipeline) *Ingester {
+	return NewIngesterWithImports(store, pipeline, pipeline)
+}
+
+// NewIngesterWithImports runs imported entries through their own pipeline, e.g. one not alerting on old traces
+func NewIngesterWithImports(store TraceStore, pipeline *Pipeline, imports *Pipeline) *Ingester {
+	return &Ingester{
+		store:    store,
+		pipeline: pipeline,
+		imports:  imports,
+		buffer:   make([]*Entry, 0),
+	}
+}
+
+func (ingester *Ingester) Ingest(entry *Entry) error {
+	ingester.lock.Lock()
+	ingester.received++
+	switch ingester.pauseMode {
+	case PauseDrop:
+		ingester.dropped++
+		ingester.lock.Unlock()
+		return nil
+	case PauseBuffer:
+		if len(ingester.buffer) >= max_paused_buffer {
+			ingester.dropped++
+		} else {
+			ingester.buffer = append(ingester.buffer, entry)
+		}
+		ingester.lock.Unlock()
+		return nil
+	}
+	ingester.lock.Unlock()
+
+	return ingester.pipeline.Ingest(ingester.store, entry)
+}
+
+// IngestImported ingests through the import pipeline regardless of capture being paused, as the user asked for it
+func (ingester *Ingester) IngestImported(entry *Entry) error {
+	return ingester.imports.Ingest(ingester.store, entry)
+}
+
+func (ingester *Ingester) Pause(mode string) error {
+	if mode != PauseBuffer && mode != PauseDrop {
+		return errors.New("pause mode must be buffer or drop")
+	}
+
+	ingester.lock.Lock()
+	defer ingester.lock.Unlock()
+	ingester.pauseMode = mode
+	ingester.resuming = false
+	return nil
+}
+
+// Resume ingests whatever was buffered while paused and then starts capturing again.
+// Entries arriving meanwhile are buffered behind the replay so the order is kept.
+// On failure the entry is counted as dropped and the rest stays buffered for the next resume.
+func (ingester *Ingester) Resume() error {
+	ingester.lock.Lock()
+	if ingester.resuming {
+		ingester.lock.Unlock()
+		return nil
+	}
+	ingester.pauseMode = PauseBuffer
+	ingester.resuming = true
+	ingester.lock.Unlock()
+
+	for {
+		ingester.lock.Lock()
+		if !ingester.resuming {
+			// paused again
+			ingester.lock.Unlock()
+			return nil
+		}
+		if len(ingester.buffer) == 0 {
+			ingester.pauseMode = ""
+			ingester.resuming = false
+			ingester.lock.Unlock()
+			return nil
+		}
+		buffered := ingester.buffer
+		ingester.buffer = make([]*Entry, 0)
+		ingester.lock.Unlock()
+
+		for i, entry := range buffered {
+			err := ingester.pipeline.Ingest(ingester.store, entry)
+			if err != nil {
+				ingester.lock.Lock()
+				ingester.dropped++
+				ingester.buffer = append(buffered[i+1:], ingester.buffer...)
+				ingester.resuming = false
+				ingester.lock.Unlock()
+				return err
+			}
+		}
+	}
+}
+
+func (ingester *Ingester) Status() *CaptureStatus {
+	ingester.lock.Lock()
+	defer ingester.lock.Unlock()
+	return &CaptureStatus{
+		Paused:   ingester.pauseMode != "",
+		Mode:     ingester.pauseMode,
+		Received: ingester.received,
+		Buffered: len(ingester.buffer),
+		Dropped:  ingester.dropped,
+	}
+}
@@ -0,0 +1,109 @@
+package tracing
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ingester_buffers_while_paused(t *testing.T) {
+	store, _ := NewInMemoryStore(EmptyConfig())
+	ingester := NewIngester(store, NewPipeline())
+	assert.Nil(t, ingester.Pause(PauseBuffer))
+
+	entry := &Entry{Trace: NewTrace(time.Now(), "hello", "12345", "info")}
+	assert.Nil(t, ingester.Ingest(entry))
+	trc, _ := store.GetById(entry.Trace.TraceId)
+	assert.Nil(t, trc)
+	assert.Equal(t, 1, ingester.Status().Buffered)
+
+	assert.Nil(t, ingester.Resume())
+	trc, _ = store.GetById(entry.Trace.TraceId)
+	assert.NotNil(t, trc)
+	assert.False(t, ingester.Status().Paused)
+}
+
+func Test_ingester_drops_while_paused(t *testing.T) {
+	store, _ := NewInMemoryStore(EmptyConfig())
+	ingester := NewIngester(store, NewPipeline())
+	assert.Nil(t, ingester.Pause(PauseDrop))
+
+	entry := &Entry{Trace: NewTrace(time.Now(), "hello", "12345", "info")}
+	assert.Nil(t, ingester.Ingest(entry))
+	assert.Nil(t, ingester.Resume())
+
+	trc, _ := store.GetById(entry.Trace.TraceId)
+	assert.Nil(t, trc)
+	status := ingester.Status()
+	assert.Equal(t, uint64(1), status.Received)
+	assert.Equal(t, uint64(1), status.Dropped)
+
+	assert.NotNil(t, ingester.Pause("sleep"))
+}
+
+type failingStore struct {
+	TraceStore
+	fail string
+}
+
+func (store *failingStore) Store(trc *Trace, payload string) error {
+	if trc.Message == store.fail {
+		return errors.New("full")
+	}
+	return store.TraceStore.Store(trc, payload)
+}
+
+type recorder struct {
+	messages []string
+}
+
+func (rec *recorder) Process(entry *Entry) ([]*Entry, error) {
+	rec.messages = append(rec.messages, entry.Trace.Message)
+	return []*Entry{entry}, nil
+}
+
+func Test_ingester_keeps_order_and_the_rest_when_resume_fails(t *testing.T) {
+	inner, _ := NewInMemoryStore(EmptyConfig())
+	store := &failingStore{TraceStore: inner, fail: "two"}
+	rec := &recorder{}
+	ingester := NewIngester(store, NewPipeline(rec))
+	assert.Nil(t, ingester.Pause(PauseBuffer))
+	for _, message := range []string{"one", "two", "three"} {
+		assert.Nil(t, ingester.Ingest(&Entry{Trace: NewTrace(time.Now(), message, "12345", "info")}))
+	}
+
+	assert.NotNil(t, ingester.Resume())
+	status := ingester.Status()
+	assert.True(t, status.Paused)
+	assert.Equal(t, 1, status.Buffered)
+	assert.Equal(t, uint64(1), status.Dropped)
+
+	// queues behind what is still buffered
+	assert.Nil(t, ingester.Ingest(&Entry{Trace: NewTrace(time.Now(), "four", "12345", "info")}))
+	assert.Nil(t, ingester.Resume())
+	assert.False(t, ingester.Status().Paused)
+	assert.Equal(t, []string{"one", "two", "three", "four"}, rec.messages)
+
+	stored := 0
+	_ = inner.Walk(nil, nil, nil, func(trc *Trace) bool {
+		stored++
+		return true
+	})
+	assert.Equal(t, 3, stored)
+}
+
+func Test_ingester_imports_through_their_own_pipeline(t *testing.T) {
+	store, _ := NewInMemoryStore(EmptyConfig())
+	live, imported := &recorder{}, &recorder{}
+	ingester := NewIngesterWithImports(store, NewPipeline(live), NewPipeline(imported))
+	assert.Nil(t, ingester.Pause(PauseDrop))
+
+	entry := &Entry{Trace: NewTrace(time.Now(), "old", "12345", "info")}
+	assert.Nil(t, ingester.IngestImported(entry))
+	assert.Equal(t, 0, len(live.messages))
+	assert.Equal(t, []string{"old"}, imported.messages)
+	trc, _ := store.GetById(entry.Trace.TraceId)
+	assert.NotNil(t, trc)
+}
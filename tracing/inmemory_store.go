@@ -1,7 +1,9 @@
 package tracing
 
 import (
+	"sort"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/hashicorp/go-memdb"
@@ -10,15 +12,23 @@ import (
 type InMemoryStore struct {
 	config   *Config
 	payloads map[string]string
+	lock     sync.Mutex // guards payloads
 	db       *memdb.MemDB
 }
 
 const (
 	tableName             = "Trace"
+	highlightTableName    = "HighlightRule"
+	sessionTableName      = "Session"
 	id_index              = "id"
 	timestamp_index       = "timestamp_idx"
+	corrid_index          = "corrid_idx"
+	session_index         = "session_idx"
 	id_column_name        = "TraceId"
 	timestamp_column_name = "TimeIndex"
+	corrid_column_name    = "CorrelationId"
+	session_column_name   = "SessionId"
+	rule_id_column_name   = "Id"
 	max_return            = 100
 )
 
@@ -37,7 +47,9 @@ func NewInMemoryStore(config *Config) (*InMemoryStore, error) {
 
 func (store *InMemoryStore) Store(trace *Trace, originalPayload string) error {
 	if store.config.KeepOriginalPayload {
+		store.lock.Lock()
 		store.payloads[trace.TraceId] = originalPayload
+		store.lock.Unlock()
 	}
 	txn := store.db.Txn(true)
 	txn.Insert("Trace", trace)
@@ -119,6 +131,282 @@ func (store *InMemoryStore) ListByTimeRange(n int, from, to *time.Time, exclusiv
 	return traces, nil
 }
 
+// without a cursor, forward pages start at from and backward pages end at to
+func (store *InMemoryStore) ListPage(n int, from, to *time.Time, match *Match, cursor *Cursor, backward bool) (*Page, error) {
+	if n <= 0 || n > max_return {
+		n = max_return
+	}
+
+	txn := store.db.Txn(false)
+	defer txn.Abort()
+	var iter memdb.ResultIterator
+	var err error
+	if backward {
+		// reverse lower bound is exclusive of keys carrying the same time index, hence the next microsecond
+		switch {
+		case cursor != nil:
+			iter, err = txn.ReverseLowerBound(tableName, timestamp_index, nextTimeIndex(cursor.TimeIndex))
+		case to != nil:
+			iter, err = txn.ReverseLowerBound(tableName, timestamp_index, strconv.FormatInt((*to).UnixMicro()+1, 10))
+		default:
+			iter, err = txn.GetReverse(tableName, timestamp_index)
+		}
+	} else {
+		switch {
+		case cursor != nil:
+			iter, err = txn.LowerBound(tableName, timestamp_index, cursor.TimeIndex)
+		case from != nil:
+			iter, err = txn.LowerBound(tableName, timestamp_index, strconv.FormatInt((*from).UnixMicro(), 10))
+		default:
+			iter, err = txn.Get(tableName, timestamp_index)
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	page := &Page{Traces: make([]*Trace, 0)}
+	for obj := iter.Next(); obj != nil; obj = iter.Next() {
+		trc := obj.(*Trace)
+		if backward {
+			if from != nil && trc.Timestamp.Before(*from) {
+				break
+			}
+			if (to != nil && trc.Timestamp.After(*to)) || (cursor != nil && cursor.compare(trc) >= 0) {
+				continue
+			}
+		} else {
+			if to != nil && trc.Timestamp.After(*to) {
+				break
+			}
+			if (from != nil && trc.Timestamp.Before(*from)) || (cursor != nil && cursor.compare(trc) <= 0) {
+				continue
+			}
+		}
+
+		if match != nil && !match.IsMatch(trc) {
+			continue
+		}
+
+		if len(page.Traces) == n {
+			page.HasMore = true
+			break
+		}
+		page.Traces = append(page.Traces, trc)
+	}
+
+	if backward {
+		for i, j := 0, len(page.Traces)-1; i < j; i, j = i+1, j-1 {
+			page.Traces[i], page.Traces[j] = page.Traces[j], page.Traces[i]
+		}
+	}
+
+	return page, nil
+}
+
+func nextTimeIndex(timeIndex string) string {
+	micros, err := strconv.ParseInt(timeIndex, 10, 64)
+	if err != nil {
+		return timeIndex
+	}
+	return strconv.FormatInt(micros+1, 10)
+}
+
+// visits every trace in the time range satisfying the match, if one is provided, without a limit
+func (store *InMemoryStore) Walk(from, to *time.Time, match *Match, fn func(trc *Trace) bool) error {
+	txn := store.db.Txn(false)
+	defer txn.Abort()
+	var iter memdb.ResultIterator
+	var err error
+	if from == nil {
+		iter, err = txn.Get(tableName, timestamp_index)
+	} else {
+		iter, err = txn.LowerBound(tableName, timestamp_index, strconv.FormatInt((*from).UnixMicro(), 10))
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for obj := iter.Next(); obj != nil; obj = iter.Next() {
+		trc := obj.(*Trace)
+		if to != nil && trc.Timestamp.After(*to) {
+			break
+		}
+
+		if match != nil && !match.IsMatch(trc) {
+			continue
+		}
+
+		if !fn(trc) {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (store *InMemoryStore) Clear() error {
+	txn := store.db.Txn(true)
+	_, err := txn.DeleteAll(tableName, id_index)
+	if err != nil {
+		txn.Abort()
+		return err
+	}
+	txn.Commit()
+
+	store.lock.Lock()
+	store.payloads = make(map[string]string)
+	store.lock.Unlock()
+	return nil
+}
+
+// returns all traces sharing the correlation id ordered by timestamp
+func (store *InMemoryStore) ListByCorrelationId(corrId string) ([]*Trace, error) {
+	return store.listByIndex(corrid_index, corrId)
+}
+
+// returns all traces of the session ordered by timestamp
+func (store *InMemoryStore) ListBySessionId(sessionId string) ([]*Trace, error) {
+	return store.listByIndex(session_index, sessionId)
+}
+
+func (store *InMemoryStore) listByIndex(index string, value string) ([]*Trace, error) {
+	txn := store.db.Txn(false)
+	defer txn.Abort()
+	iter, err := txn.Get(tableName, index, value)
+	if err != nil {
+		return nil, err
+	}
+
+	traces := make([]*Trace, 0)
+	for obj := iter.Next(); obj != nil; obj = iter.Next() {
+		traces = append(traces, obj.(*Trace))
+	}
+
+	sort.SliceStable(traces, func(i, j int) bool {
+		return traces[i].Timestamp.Before(traces[j].Timestamp)
+	})
+
+	return traces, nil
+}
+
+func (store *InMemoryStore) SaveHighlightRule(rule *HighlightRule) error {
+	txn := store.db.Txn(true)
+	err := txn.Insert(highlightTableName, rule)
+	if err != nil {
+		txn.Abort()
+		return err
+	}
+	txn.Commit()
+	return nil
+}
+
+// returns false if the rule did not exist
+func (store *InMemoryStore) DeleteHighlightRule(id string) (bool, error) {
+	txn := store.db.Txn(true)
+	deleted, err := txn.DeleteAll(highlightTableName, id_index, id)
+	if err != nil {
+		txn.Abort()
+		return false, err
+	}
+	txn.Commit()
+	return deleted > 0, nil
+}
+
+func (store *InMemoryStore) ListHighlightRules() ([]*HighlightRule, error) {
+	txn := store.db.Txn(false)
+	defer txn.Abort()
+	iter, err := txn.Get(highlightTableName, id_index)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]*HighlightRule, 0)
+	for obj := iter.Next(); obj != nil; obj = iter.Next() {
+		rules = append(rules, obj.(*HighlightRule))
+	}
+
+	return rules, nil
+}
+
+func (store *InMemoryStore) SaveSession(session *Session) error {
+	txn := store.db.Txn(true)
+	err := txn.Insert(sessionTableName, session)
+	if err != nil {
+		txn.Abort()
+		return err
+	}
+	txn.Commit()
+	return nil
+}
+
+// returns null if not found
+func (store *InMemoryStore) GetSession(id string) (*Session, error) {
+	txn := store.db.Txn(false)
+	defer txn.Abort()
+	session, err := txn.First(sessionTableName, id_index, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if session == nil {
+		return nil, nil
+	}
+
+	return session.(*Session), nil
+}
+
+// returns false if the session did not exist
+func (store *InMemoryStore) DeleteSession(id string) (bool, error) {
+	traces, err := store.ListBySessionId(id)
+	if err != nil {
+		return false, err
+	}
+
+	txn := store.db.Txn(true)
+	deleted, err := txn.DeleteAll(sessionTableName, id_index, id)
+	if err == nil {
+		_, err = txn.DeleteAll(tableName, session_index, id)
+	}
+
+	if err != nil {
+		txn.Abort()
+		return false, err
+	}
+	txn.Commit()
+
+	store.lock.Lock()
+	for _, trc := range traces {
+		delete(store.payloads, trc.TraceId)
+	}
+	store.lock.Unlock()
+
+	return deleted > 0, nil
+}
+
+// returns sessions in the order they were started
+func (store *InMemoryStore) ListSessions() ([]*Session, error) {
+	txn := store.db.Txn(false)
+	defer txn.Abort()
+	iter, err := txn.Get(sessionTableName, id_index)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*Session, 0)
+	for obj := iter.Next(); obj != nil; obj = iter.Next() {
+		sessions = append(sessions, obj.(*Session))
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].Started.Before(sessions[j].Started)
+	})
+
+	return sessions, nil
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -143,6 +431,38 @@ func getSchema() *memdb.DBSchema {
 						Unique:  false,
 						Indexer: &memdb.StringFieldIndex{Field: timestamp_column_name},
 					},
+					"corrid_idx": {
+						Name:         corrid_index,
+						Unique:       false,
+						AllowMissing: true,
+						Indexer:      &memdb.StringFieldIndex{Field: corrid_column_name},
+					},
+					"session_idx": {
+						Name:         session_index,
+						Unique:       false,
+						AllowMissing: true,
+						Indexer:      &memdb.StringFieldIndex{Field: session_column_name},
+					},
+				},
+			},
+			"HighlightRule": {
+				Name: highlightTableName,
+				Indexes: map[string]*memdb.IndexSchema{
+					"id": {
+						Name:    id_index,
+						Unique:  true,
+						Indexer: &memdb.StringFieldIndex{Field: rule_id_column_name},
+					},
+				},
+			},
+			"Session": {
+				Name: sessionTableName,
+				Indexes: map[string]*memdb.IndexSchema{
+					"id": {
+						Name:    id_index,
+						Unique:  true,
+						Indexer: &memdb.StringFieldIndex{Field: rule_id_column_name},
+					},
 				},
 			},
 		},
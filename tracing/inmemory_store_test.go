@@ -141,3 +141,56 @@ func getTrace(t *time.Time) *Trace {
 	trc := NewTrace(*t, "hello", "12345", "info")
 	return trc
 }
+
+func Test_list_by_correlation_id(t *testing.T) {
+	store, err := NewInMemoryStore(EmptyConfig())
+	assert.Nil(t, err)
+	now := time.Now().UTC()
+	second := NewTrace(now, "second", "12345", "info")
+	first := NewTrace(now.Add(-1*time.Second), "first", "12345", "info")
+	_ = store.Store(second, "")
+	_ = store.Store(first, "")
+	_ = store.Store(NewTrace(now, "other", "6789", "info"), "")
+	_ = store.Store(NewTrace(now, "none", "", "info"), "")
+
+	traces, err := store.ListByCorrelationId("12345")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(traces))
+	assert.Equal(t, first.TraceId, traces[0].TraceId)
+}
+
+func Test_clear(t *testing.T) {
+	store, err := NewInMemoryStore(&Config{KeepOriginalPayload: true})
+	assert.Nil(t, err)
+	trace := NewTrace(time.Now(), "hello", "12345", "info")
+	_ = store.Store(trace, "hello")
+	_ = store.SaveHighlightRule(&HighlightRule{Id: "rule", Name: "errors"})
+
+	assert.Nil(t, store.Clear())
+	trc, err := store.GetById(trace.TraceId)
+	assert.Nil(t, err)
+	assert.Nil(t, trc)
+	rules, _ := store.ListHighlightRules()
+	assert.Equal(t, 1, len(rules))
+}
+
+func Test_walk_is_not_capped(t *testing.T) {
+	store, err := NewInMemoryStore(EmptyConfig())
+	assert.Nil(t, err)
+	from := time.Now().UTC().Add(-10 * 24 * time.Hour)
+	to := time.Now().UTC().Add(-1 * 24 * time.Hour)
+	for _, trc := range getRandomTraces(250, &from, &to) {
+		_ = store.Store(trc, "")
+	}
+
+	var last time.Time
+	count := 0
+	err = store.Walk(&from, &to, nil, func(trc *Trace) bool {
+		assert.False(t, trc.Timestamp.Before(last))
+		last = trc.Timestamp
+		count++
+		return true
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 250, count)
+}
@@ -0,0 +1,117 @@
+package tracing
+
+import (
+	"regexp"
+
+	"golang.org/x/exp/slices"
+)
+
+// Match is a set of criteria on a trace which all have to be satisfied.
+// Patterns are regular expressions and empty criteria match everything.
+type Match struct {
+	Levels         []string
+	MessagePattern string
+	Properties     map[string]string // property name to the pattern its value has to match
+	SourcePattern  string
+	Highlights     []string // ids of highlight rules, any of which the trace has to carry
+	SessionId      string
+	CorrelationId  string
+	PatternIds     []string // ids of message patterns, any of which the trace has to belong to
+	Expression     string   // a boolean expression, e.g. Metrics.latency_ms > 500 && Level != "debug"
+
+	message    *regexp.Regexp
+	expression *Expression
+	source     *regexp.Regexp
+	properties map[string]*regexp.Regexp
+}
+
+// Compile has to be called before the match is used and after every change to its criteria
+func (m *Match) Compile() error {
+	var err error
+	m.message, err = compileOptional(m.MessagePattern)
+	if err != nil {
+		return err
+	}
+
+	m.source, err = compileOptional(m.SourcePattern)
+	if err != nil {
+		return err
+	}
+
+	m.expression = nil
+	if m.Expression != "" {
+		m.expression, err = CompileExpression(m.Expression)
+		if err != nil {
+			return err
+		}
+	}
+
+	m.properties = make(map[string]*regexp.Regexp)
+	for name, pattern := range m.Properties {
+		m.properties[name], err = regexp.Compile(pattern)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *Match) IsMatch(trc *Trace) bool {
+	if len(m.Levels) > 0 && !slices.Contains(m.Levels, trc.Level) {
+		return false
+	}
+
+	if m.message != nil && !m.message.MatchString(trc.Message) {
+		return false
+	}
+
+	if m.source != nil && !m.source.MatchString(trc.Source) {
+		return false
+	}
+
+	if m.SessionId != "" && trc.SessionId != m.SessionId {
+		return false
+	}
+
+	if m.CorrelationId != "" && trc.CorrelationId != m.CorrelationId {
+		return false
+	}
+
+	if len(m.PatternIds) > 0 && !slices.Contains(m.PatternIds, trc.PatternId) {
+		return false
+	}
+
+	if len(m.Highlights) > 0 && !containsAny(trc.Highlights, m.Highlights) {
+		return false
+	}
+
+	for name, re := range m.properties {
+		value, ok := trc.Properties[name]
+		if !ok || !re.MatchString(value) {
+			return false
+		}
+	}
+
+	if m.expression != nil && !m.expression.IsTrue(trc) {
+		return false
+	}
+
+	return true
+}
+
+func containsAny(values []string, candidates []string) bool {
+	for _, candidate := range candidates {
+		if slices.Contains(values, candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+func compileOptional(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile(pattern)
+}
@@ -0,0 +1,143 @@
+package tracing
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"golang.org/x/exp/maps"
+)
+
+type MetricQuery struct {
+	Name   string
+	From   *time.Time
+	To     *time.Time
+	Match  *Match
+	Bucket time.Duration // zero picks a size giving about 60 buckets
+}
+
+// MetricBucket summarises the values of a metric in a time bucket, all zero when Count is zero
+type MetricBucket struct {
+	Start time.Time
+	Count int
+	Min   float64
+	Max   float64
+	Avg   float64
+	Sum   float64
+}
+
+type MetricSeries struct {
+	Name     string
+	Start    time.Time
+	End      time.Time
+	BucketMs float64
+	Buckets  []*MetricBucket
+	Count    int
+	Min      float64
+	Max      float64
+	Avg      float64
+	P50      float64
+	P95      float64
+	P99      float64
+}
+
+// MetricStats returns the time series of a metric for the traces matching the query,
+// with statistics and percentiles over the whole range
+func MetricStats(store TraceStore, query *MetricQuery) (*MetricSeries, error) {
+	series := &MetricSeries{
+		Name:    query.Name,
+		Buckets: make([]*MetricBucket, 0),
+	}
+
+	buckets, err := newTimeBuckets(store, query.From, query.To, query.Match, query.Bucket)
+	if err != nil || buckets == nil {
+		return series, err
+	}
+
+	series.Start = buckets.start
+	series.End = buckets.end
+	series.BucketMs = toMs(buckets.size)
+	for i := 0; i < buckets.count; i++ {
+		series.Buckets = append(series.Buckets, &MetricBucket{Start: buckets.bucketStart(i)})
+	}
+
+	values := make([]float64, 0)
+	err = store.Walk(&buckets.from, &buckets.end, query.Match, func(trc *Trace) bool {
+		value, ok := trc.Metrics[query.Name]
+		if !ok || math.IsNaN(value) {
+			return true
+		}
+
+		i := buckets.index(trc.Timestamp)
+		if i < 0 {
+			return true
+		}
+
+		series.Buckets[i].add(value)
+		values = append(values, value)
+		return true
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(values) == 0 {
+		return series, nil
+	}
+
+	sort.Float64s(values)
+	sum := 0.0
+	for _, value := range values {
+		sum += value
+	}
+
+	series.Count = len(values)
+	series.Min = values[0]
+	series.Max = values[len(values)-1]
+	series.Avg = sum / float64(len(values))
+	series.P50 = percentile(values, 50)
+	series.P95 = percentile(values, 95)
+	series.P99 = percentile(values, 99)
+	return series, nil
+}
+
+func (bucket *MetricBucket) add(value float64) {
+	if bucket.Count == 0 || value < bucket.Min {
+		bucket.Min = value
+	}
+	if bucket.Count == 0 || value > bucket.Max {
+		bucket.Max = value
+	}
+	bucket.Count++
+	bucket.Sum += value
+	bucket.Avg = bucket.Sum / float64(bucket.Count)
+}
+
+// nearest-rank percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// ListMetricNames returns the sorted names of the metrics carried by the traces matching in the range
+func ListMetricNames(store TraceStore, from, to *time.Time, match *Match) ([]string, error) {
+	names := make(map[string]bool)
+	err := store.Walk(from, to, match, func(trc *Trace) bool {
+		for name := range trc.Metrics {
+			names[name] = true
+		}
+		return true
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := maps.Keys(names)
+	sort.Strings(result)
+	return result, nil
+}
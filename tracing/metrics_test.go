@@ -0,0 +1,54 @@
+package tracing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_metric_stats(t *testing.T) {
+	store := getAggregateStore(t)
+	to := aggregateStart.Add(5 * time.Minute)
+	series, err := MetricStats(store, &MetricQuery{
+		Name:   "took",
+		From:   &aggregateStart,
+		To:     &to,
+		Bucket: time.Minute,
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 30, series.Count)
+	assert.Equal(t, 0.0, series.Min)
+	assert.Equal(t, 29.0, series.Max)
+	assert.Equal(t, 14.5, series.Avg)
+	assert.Equal(t, 14.0, series.P50)
+	assert.Equal(t, 28.0, series.P95)
+	assert.Equal(t, 29.0, series.P99)
+
+	first := series.Buckets[0]
+	assert.Equal(t, 6, first.Count)
+	assert.Equal(t, 0.0, first.Min)
+	assert.Equal(t, 5.0, first.Max)
+	assert.Equal(t, 15.0, first.Sum)
+	assert.Equal(t, 2.5, first.Avg)
+	assert.Equal(t, 0, series.Buckets[5].Count)
+}
+
+func Test_metric_stats_of_unknown_metric(t *testing.T) {
+	store := getAggregateStore(t)
+	series, err := MetricStats(store, &MetricQuery{Name: "nope", From: &aggregateStart})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, series.Count)
+}
+
+func Test_list_metric_names(t *testing.T) {
+	store := getAggregateStore(t)
+	trc := NewTrace(aggregateStart, "hello", "12345", "info")
+	trc.Metrics["queue_depth"] = 3
+	_ = store.Store(trc, "")
+
+	names, err := ListMetricNames(store, nil, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"queue_depth", "took"}, names)
+}
@@ -118,18 +118,52 @@ func (parser *PayloadParser) parseJson(payload string, jsonMap map[string]interf
 		delete(jsonMap, corrIdFieldName)
 	}
 
+	// ______________________ SPANS ______________________
 	trc := NewTrace(timestamp, message, corrId, level)
+	trc.SpanId, trc.ParentSpanId = parser.findSpanIds(jsonMap,
+		[]string{"spanId", "SpanId", "span_id"},
+		[]string{"parentSpanId", "ParentSpanId", "parent_span_id"})
+
 	populatePropertiesAndMetrics(jsonMap, trc)
 	return trc, nil
 }
 
+// finds span and parent span ids using the configured field names or else the provided guesses
+func (parser *PayloadParser) findSpanIds(jsonMap map[string]interface{}, spanFieldNames []string, parentFieldNames []string) (string, string) {
+	spanId := takeIdField(jsonMap, parser.config.SpanIdFieldNames, spanFieldNames)
+	parentSpanId := takeIdField(jsonMap, parser.config.ParentSpanIdFieldNames, parentFieldNames)
+	return spanId, parentSpanId
+}
+
+// removes the first of the fields holding an id, which can be a string or a number, and returns its value
+func takeIdField(jsonMap map[string]interface{}, configFieldNames []string, fieldNames []string) string {
+	if len(configFieldNames) > 0 {
+		fieldNames = configFieldNames
+	}
+
+	for _, fieldName := range fieldNames {
+		var id string
+		switch value := jsonMap[fieldName].(type) {
+		case string:
+			id = value
+		case float64:
+			id = strconv.FormatFloat(value, 'f', -1, 64)
+		}
+
+		if id != "" {
+			delete(jsonMap, fieldName)
+			return id
+		}
+	}
+
+	return ""
+}
+
 func findStringField(jsonMap map[string]interface{}, configFieldNames []string, fieldNames ...string) (string, bool) {
 	if len(configFieldNames) > 0 {
 		for _, fieldName := range configFieldNames {
-			if value, ok := jsonMap[fieldName]; ok {
-				if value.(string) != "" {
-					return fieldName, true
-				}
+			if value, ok := jsonMap[fieldName].(string); ok && value != "" {
+				return fieldName, true
 			}
 		}
 	} else {
@@ -168,7 +202,9 @@ func findTimestampField(jsonMap map[string]interface{}, fieldNames ...string) (s
 @x	Exception	A language-dependent error representation potentially including backtrace
 @i	Event id	An implementation specific event id (string or number)
 @r	Renderings	If @mt includes tokens with programming-language-specific formatting, an array of pre-rendered values for each such token	May be omitted; if present, the count of renderings must match the count of formatted tokens exactly
-
+@tr	Trace id	The id of the distributed trace the event belongs to	Used as correlation id if none configured
+@sp	Span id	The id of the span the event belongs to
+@ps	Parent span id	The id of the parent of the span
 */
 func (parser *PayloadParser) parseClef(payload string, jsonMap map[string]interface{}) (*Trace, error) {
 	timestamp, err := parseDate(jsonMap["@t"])
@@ -199,9 +235,15 @@ func (parser *PayloadParser) parseClef(payload string, jsonMap map[string]interf
 	if corrIdFieldName != "" {
 		corrId = jsonMap[corrIdFieldName].(string)
 		delete(jsonMap, corrIdFieldName)
+	} else {
+		corrId = safeGetValue(jsonMap, "@tr")
+		if corrId != "" {
+			delete(jsonMap, "@tr")
+		}
 	}
 
 	trc := NewTrace(timestamp, message, corrId, level)
+	trc.SpanId, trc.ParentSpanId = parser.findSpanIds(jsonMap, []string{"@sp"}, []string{"@ps"})
 
 	populatePropertiesAndMetrics(jsonMap, trc)
 
@@ -206,3 +206,46 @@ func Test_getting_time_from_epoch_nano(t *testing.T) {
 	assert.True(t, t1.Equal(t2))
 
 }
+
+func TestParser_nonclef_span_ids(t *testing.T) {
+	json := `{"Timestamp":"2016-11-21T11:22:33Z","message": "I was here!","spanId":"b","parentSpanId":"a","foo":"sumagh"}`
+	parser := NewPayloadParser()
+	trc, err := parser.Parse(json)
+	assert.Nil(t, err)
+	assert.Equal(t, "b", trc.SpanId)
+	assert.Equal(t, "a", trc.ParentSpanId)
+	assert.Equal(t, 1, len(trc.Properties))
+}
+
+func TestParser_nonclef_parent_id_is_not_a_span_id(t *testing.T) {
+	json := `{"Timestamp":"2016-11-21T11:22:33Z","message": "order placed","parentId":"customer-42"}`
+	parser := NewPayloadParser()
+	trc, err := parser.Parse(json)
+	assert.Nil(t, err)
+	assert.Equal(t, "", trc.ParentSpanId)
+	assert.Equal(t, "customer-42", trc.Properties["parentId"])
+}
+
+func TestParser_configured_span_ids_can_be_numbers(t *testing.T) {
+	json := `{"Timestamp":"2016-11-21T11:22:33Z","message": "I was here!","sid":12,"psid":"a","level":3}`
+	config := EmptyConfig()
+	config.SpanIdFieldNames = []string{"sid"}
+	config.ParentSpanIdFieldNames = []string{"psid"}
+	config.LevelFieldNames = []string{"level"}
+	parser := NewPayloadParserWithConfig(config)
+	trc, err := parser.Parse(json)
+	assert.Nil(t, err)
+	assert.Equal(t, "12", trc.SpanId)
+	assert.Equal(t, "a", trc.ParentSpanId)
+}
+
+func TestParser_clef_trace_and_span_ids(t *testing.T) {
+	json := `{"@t":"2016-11-21T11:22:33Z","@m":"hello","@tr":"12345","@sp":"b","@ps":"a"}`
+	parser := NewPayloadParser()
+	trc, err := parser.Parse(json)
+	assert.Nil(t, err)
+	assert.Equal(t, "12345", trc.CorrelationId)
+	assert.Equal(t, "b", trc.SpanId)
+	assert.Equal(t, "a", trc.ParentSpanId)
+	assert.Equal(t, 0, len(trc.Properties))
+}
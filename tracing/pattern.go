@@ -0,0 +1,242 @@
+package tracing
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+const (
+	PatternWildcard = "<*>"
+
+	pattern_depth        = 4   // levels of the parse tree, including the root and the leaves
+	pattern_similarity   = 0.4 // share of tokens a message has to have in common with a template to join it
+	pattern_max_children = 100 // children of a tree node beyond which tokens go under the wildcard
+	pattern_examples     = 3
+)
+
+// Pattern is a message template mined from the traces, variable tokens are replaced by <*>
+type Pattern struct {
+	Id        string
+	Template  string
+	Count     uint64
+	FirstSeen time.Time
+	LastSeen  time.Time
+	Examples  []string // ids of the first traces of the pattern
+
+	tokens []string
+}
+
+type patternNode struct {
+	children map[string]*patternNode
+	patterns []*Pattern
+}
+
+func newPatternNode() *patternNode {
+	return &patternNode{children: make(map[string]*patternNode)}
+}
+
+// PatternMiner clusters messages into templates online, following Drain (He et al. 2017):
+// messages are routed through a fixed depth tree by their token count and first tokens,
+// then join the most similar template of the leaf or start a new one.
+type PatternMiner struct {
+	lock     sync.RWMutex
+	root     *patternNode
+	patterns map[string]*Pattern
+}
+
+func NewPatternMiner() *PatternMiner {
+	return &PatternMiner{
+		root:     newPatternNode(),
+		patterns: make(map[string]*Pattern),
+	}
+}
+
+// Process sets the pattern id of the trace. It is counted by the Counter stage, once it is known to be kept.
+func (miner *PatternMiner) Process(entry *Entry) ([]*Entry, error) {
+	entry.Trace.PatternId = miner.Assign(entry.Trace)
+	return []*Entry{entry}, nil
+}
+
+type patternCounter struct {
+	miner *PatternMiner
+}
+
+func (counter *patternCounter) Process(entry *Entry) ([]*Entry, error) {
+	counter.miner.Count(entry.Trace)
+	return []*Entry{entry}, nil
+}
+
+// Counter is the stage counting traces in their patterns, placed after the stages which drop traces
+// so counts and examples only cover stored traces
+func (miner *PatternMiner) Counter() Processor {
+	return &patternCounter{miner: miner}
+}
+
+// Add assigns the trace its pattern and counts it there
+func (miner *PatternMiner) Add(trc *Trace) *Pattern {
+	trc.PatternId = miner.Assign(trc)
+	miner.Count(trc)
+	return miner.Get(trc.PatternId)
+}
+
+// Assign finds or creates the pattern of the trace's message, widening its template, and returns its id
+func (miner *PatternMiner) Assign(trc *Trace) string {
+	tokens := patternTokens(trc.Message)
+	miner.lock.Lock()
+	defer miner.lock.Unlock()
+
+	leaf := miner.leaf(tokens)
+	pattern := bestPattern(leaf.patterns, tokens)
+	if pattern == nil {
+		pattern = &Pattern{
+			Id:       uuid.New().String(),
+			Examples: make([]string, 0, pattern_examples),
+			tokens:   tokens,
+		}
+		leaf.patterns = append(leaf.patterns, pattern)
+		miner.patterns[pattern.Id] = pattern
+	} else {
+		for i, token := range tokens {
+			if pattern.tokens[i] != token {
+				pattern.tokens[i] = PatternWildcard
+			}
+		}
+	}
+
+	pattern.Template = strings.Join(pattern.tokens, " ")
+	return pattern.Id
+}
+
+// Count counts the trace in the pattern it was assigned
+func (miner *PatternMiner) Count(trc *Trace) {
+	miner.lock.Lock()
+	defer miner.lock.Unlock()
+	pattern, ok := miner.patterns[trc.PatternId]
+	if !ok {
+		return
+	}
+
+	if pattern.Count == 0 || trc.Timestamp.Before(pattern.FirstSeen) {
+		pattern.FirstSeen = trc.Timestamp
+	}
+	if pattern.Count == 0 || trc.Timestamp.After(pattern.LastSeen) {
+		pattern.LastSeen = trc.Timestamp
+	}
+	pattern.Count++
+	if len(pattern.Examples) < pattern_examples {
+		pattern.Examples = append(pattern.Examples, trc.TraceId)
+	}
+}
+
+// walks down the tree by the token count then the first tokens, creating the nodes as it goes
+func (miner *PatternMiner) leaf(tokens []string) *patternNode {
+	node := miner.root.child(strconv.Itoa(len(tokens)))
+	for depth := 0; depth < pattern_depth-2 && depth < len(tokens); depth++ {
+		key := tokens[depth]
+		if _, ok := node.children[key]; !ok && len(node.children) >= pattern_max_children {
+			key = PatternWildcard
+		}
+		node = node.child(key)
+	}
+	return node
+}
+
+func (node *patternNode) child(key string) *patternNode {
+	child, ok := node.children[key]
+	if !ok {
+		child = newPatternNode()
+		node.children[key] = child
+	}
+	return child
+}
+
+// the pattern sharing the most tokens with the message, ties going to the one with more wildcards
+func bestPattern(patterns []*Pattern, tokens []string) *Pattern {
+	var best *Pattern
+	bestSimilarity, bestWildcards := -1.0, -1
+	for _, pattern := range patterns {
+		same, wildcards := 0, 0
+		for i, token := range pattern.tokens {
+			if token == PatternWildcard {
+				wildcards++
+			} else if token == tokens[i] {
+				same++
+			}
+		}
+
+		similarity := 1.0
+		if len(tokens) > 0 {
+			similarity = float64(same) / float64(len(tokens))
+		}
+		if similarity > bestSimilarity || (similarity == bestSimilarity && wildcards > bestWildcards) {
+			best, bestSimilarity, bestWildcards = pattern, similarity, wildcards
+		}
+	}
+
+	if best == nil || bestSimilarity < pattern_similarity {
+		return nil
+	}
+	return best
+}
+
+// splits the message on white space, tokens with digits are taken as variables up front
+func patternTokens(message string) []string {
+	tokens := strings.Fields(message)
+	for i, token := range tokens {
+		if strings.IndexFunc(token, unicode.IsDigit) >= 0 {
+			tokens[i] = PatternWildcard
+		}
+	}
+	return tokens
+}
+
+func (pattern *Pattern) copy() *Pattern {
+	copied := *pattern
+	copied.Examples = append([]string{}, pattern.Examples...)
+	copied.tokens = nil
+	return &copied
+}
+
+// Reset forgets every pattern, e.g. once the traces are cleared
+func (miner *PatternMiner) Reset() {
+	miner.lock.Lock()
+	defer miner.lock.Unlock()
+	miner.root = newPatternNode()
+	miner.patterns = make(map[string]*Pattern)
+}
+
+// List returns copies of the patterns, most frequent first, leaving out those no stored trace belongs to
+func (miner *PatternMiner) List() []*Pattern {
+	miner.lock.RLock()
+	defer miner.lock.RUnlock()
+	patterns := make([]*Pattern, 0, len(miner.patterns))
+	for _, pattern := range miner.patterns {
+		if pattern.Count > 0 {
+			patterns = append(patterns, pattern.copy())
+		}
+	}
+
+	sort.Slice(patterns, func(i, j int) bool {
+		if patterns[i].Count != patterns[j].Count {
+			return patterns[i].Count > patterns[j].Count
+		}
+		return patterns[i].FirstSeen.Before(patterns[j].FirstSeen)
+	})
+	return patterns
+}
+
+func (miner *PatternMiner) Get(id string) *Pattern {
+	miner.lock.RLock()
+	defer miner.lock.RUnlock()
+	pattern, ok := miner.patterns[id]
+	if !ok || pattern.Count == 0 {
+		return nil
+	}
+	return pattern.copy()
+}
@@ -0,0 +1,98 @@
+package tracing
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mine(miner *PatternMiner, message string) *Trace {
+	trc := NewTrace(time.Now(), message, "12345", "info")
+	_, _ = NewPipeline(miner, miner.Counter()).Process(&Entry{Trace: trc})
+	return trc
+}
+
+func Test_pattern_miner_clusters_messages(t *testing.T) {
+	miner := NewPatternMiner()
+	for i := 0; i < 20; i++ {
+		mine(miner, fmt.Sprintf("request %d took %dms", i, i*3))
+		mine(miner, fmt.Sprintf("user logged in as %s from web", []string{"jo", "al", "mo"}[i%3]))
+	}
+	mine(miner, "connection refused")
+
+	patterns := miner.List()
+	assert.Equal(t, 3, len(patterns))
+	assert.Equal(t, uint64(20), patterns[0].Count)
+	assert.Equal(t, "connection refused", patterns[2].Template)
+
+	templates := []string{patterns[0].Template, patterns[1].Template}
+	assert.Contains(t, templates, "request <*> took <*>")
+	assert.Contains(t, templates, "user logged in as <*> from web")
+}
+
+func Test_pattern_miner_assigns_ids_and_examples(t *testing.T) {
+	miner := NewPatternMiner()
+	first := mine(miner, "cache miss for key a")
+	second := mine(miner, "cache miss for key b")
+	other := mine(miner, "disk full")
+	for i := 0; i < 5; i++ {
+		mine(miner, "cache miss for key c")
+	}
+
+	assert.NotEmpty(t, first.PatternId)
+	assert.Equal(t, first.PatternId, second.PatternId)
+	assert.NotEqual(t, first.PatternId, other.PatternId)
+
+	pattern := miner.Get(first.PatternId)
+	assert.Equal(t, "cache miss for key <*>", pattern.Template)
+	assert.Equal(t, uint64(7), pattern.Count)
+	assert.Equal(t, pattern_examples, len(pattern.Examples))
+	assert.Equal(t, first.TraceId, pattern.Examples[0])
+	assert.Nil(t, miner.Get("nope"))
+}
+
+func Test_pattern_miner_keeps_different_lengths_apart(t *testing.T) {
+	miner := NewPatternMiner()
+	short := mine(miner, "job done")
+	long := mine(miner, "job done in time")
+	assert.NotEqual(t, short.PatternId, long.PatternId)
+}
+
+func Test_match_pattern_ids(t *testing.T) {
+	miner := NewPatternMiner()
+	trc := mine(miner, "job done")
+	match := &Match{PatternIds: []string{trc.PatternId}}
+	assert.Nil(t, match.Compile())
+	assert.True(t, match.IsMatch(trc))
+	assert.False(t, match.IsMatch(mine(miner, "something else entirely here")))
+}
+
+func Test_pattern_miner_only_counts_kept_traces(t *testing.T) {
+	miner := NewPatternMiner()
+	filters := NewFilterRules()
+	_, err := filters.Add(&FilterRule{Action: FilterExclude, Match: Match{MessagePattern: "noise"}})
+	assert.Nil(t, err)
+	pipeline := NewPipeline(miner, filters, miner.Counter())
+
+	kept := NewTrace(time.Now(), "job done", "12345", "info")
+	_, _ = pipeline.Process(&Entry{Trace: kept})
+	_, _ = pipeline.Process(&Entry{Trace: NewTrace(time.Now(), "job noise", "12345", "info")})
+	_, _ = pipeline.Process(&Entry{Trace: NewTrace(time.Now(), "noise only here", "12345", "info")})
+
+	patterns := miner.List()
+	assert.Equal(t, 1, len(patterns))
+	assert.Equal(t, uint64(1), patterns[0].Count)
+	assert.Equal(t, []string{kept.TraceId}, patterns[0].Examples)
+}
+
+func Test_pattern_miner_reset(t *testing.T) {
+	miner := NewPatternMiner()
+	trc := mine(miner, "job done")
+	miner.Reset()
+
+	assert.Equal(t, 0, len(miner.List()))
+	assert.Nil(t, miner.Get(trc.PatternId))
+	assert.NotEqual(t, trc.PatternId, mine(miner, "job done").PatternId)
+}
@@ -0,0 +1,347 @@
+package tracing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Entry is a parsed trace on its way to the store along with its original payload
+type Entry struct {
+	Trace   *Trace
+	Payload string
+}
+
+// Processor sees every entry between parsing and storing. Returning no entries
+// drops the entry and returning more than one splits it.
+type Processor interface {
+	Process(entry *Entry) ([]*Entry, error)
+}
+
+// Resettable is state built from the traces as they pass, which is forgotten when all traces are cleared
+type Resettable interface {
+	Reset()
+}
+
+// Pipeline runs entries through a chain of processors
+type Pipeline struct {
+	processors []Processor
+}
+
+func NewPipeline(processors ...Processor) *Pipeline {
+	return &Pipeline{
+		processors: processors,
+	}
+}
+
+func (pipeline *Pipeline) Process(entry *Entry) ([]*Entry, error) {
+	entries := []*Entry{entry}
+	for _, processor := range pipeline.processors {
+		next := make([]*Entry, 0, len(entries))
+		for _, e := range entries {
+			processed, err := processor.Process(e)
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, processed...)
+		}
+
+		entries = next
+		if len(entries) == 0 {
+			break
+		}
+	}
+
+	return entries, nil
+}
+
+// Ingest processes the entry and stores whatever comes out of the pipeline
+func (pipeline *Pipeline) Ingest(store TraceStore, entry *Entry) error {
+	entries, err := pipeline.Process(entry)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		err = store.Store(e.Trace, e.Payload)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RedactedStages runs the configured processors and then the redactor, so what they extract or create is redacted too
+func RedactedStages(redactor *Redactor, processors []Processor) []Processor {
+	return append(append([]Processor{}, processors...), redactor)
+}
+
+const (
+	ProcessorAdd     = "add"     // adds static Properties
+	ProcessorRename  = "rename"  // renames properties and metrics according to Renames
+	ProcessorPromote = "promote" // turns numeric properties listed in Fields into metrics
+	ProcessorJson    = "json"    // parses JSON embedded in Field into properties and metrics
+	ProcessorRegex   = "regex"   // extracts the named groups of Pattern from Field into properties
+	ProcessorDrop    = "drop"    // drops entries satisfying Match
+	ProcessorSplit   = "split"   // splits the message on Pattern into separate traces
+	ProcessorRoute   = "route"   // runs Processors only on entries satisfying Match
+	ProcessorCompute = "compute" // evaluates Expressions of the form name = expression into metrics or properties
+)
+
+// ProcessorConfig declares a built-in processor. Which fields apply depends on the type.
+type ProcessorConfig struct {
+	Type        string
+	Properties  map[string]string
+	Renames     map[string]string
+	Fields      []string
+	Field       string // Message or the name of a property, defaults to Message
+	Pattern     string
+	Match       *Match
+	Processors  []*ProcessorConfig
+	Expressions []string
+}
+
+// LoadProcessors reads a JSON array of processor declarations from a file
+func LoadProcessors(path string) ([]Processor, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var configs []*ProcessorConfig
+	err = json.Unmarshal(data, &configs)
+	if err != nil {
+		return nil, err
+	}
+
+	return BuildProcessors(configs)
+}
+
+func BuildProcessors(configs []*ProcessorConfig) ([]Processor, error) {
+	processors := make([]Processor, 0, len(configs))
+	for _, cfg := range configs {
+		processor, err := BuildProcessor(cfg)
+		if err != nil {
+			return nil, err
+		}
+		processors = append(processors, processor)
+	}
+	return processors, nil
+}
+
+func BuildProcessor(cfg *ProcessorConfig) (Processor, error) {
+	field := cfg.Field
+	if field == "" {
+		field = "Message"
+	}
+
+	switch cfg.Type {
+	case ProcessorAdd:
+		return &addProcessor{properties: cfg.Properties}, nil
+	case ProcessorRename:
+		return &renameProcessor{renames: cfg.Renames}, nil
+	case ProcessorPromote:
+		return &promoteProcessor{fields: cfg.Fields}, nil
+	case ProcessorJson:
+		return &jsonProcessor{field: field}, nil
+	case ProcessorRegex:
+		re, err := regexp.Compile(cfg.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(re.SubexpNames()) < 2 {
+			return nil, errors.New("regex processor pattern needs named groups")
+		}
+		return &regexProcessor{field: field, re: re}, nil
+	case ProcessorDrop:
+		match, err := compileProcessorMatch(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return &dropProcessor{match: match}, nil
+	case ProcessorSplit:
+		if cfg.Pattern == "" {
+			return nil, errors.New("split processor needs a pattern")
+		}
+		re, err := regexp.Compile(cfg.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		return &splitProcessor{re: re}, nil
+	case ProcessorRoute:
+		match, err := compileProcessorMatch(cfg)
+		if err != nil {
+			return nil, err
+		}
+		processors, err := BuildProcessors(cfg.Processors)
+		if err != nil {
+			return nil, err
+		}
+		return &routeProcessor{match: match, pipeline: NewPipeline(processors...)}, nil
+	case ProcessorCompute:
+		assignments := make([]*Assignment, 0, len(cfg.Expressions))
+		for _, source := range cfg.Expressions {
+			assignment, err := CompileAssignment(source)
+			if err != nil {
+				return nil, err
+			}
+			assignments = append(assignments, assignment)
+		}
+		return &computeProcessor{assignments: assignments}, nil
+	default:
+		return nil, fmt.Errorf("unknown processor type %q", cfg.Type)
+	}
+}
+
+func compileProcessorMatch(cfg *ProcessorConfig) (*Match, error) {
+	if cfg.Match == nil {
+		return nil, fmt.Errorf("%s processor needs a match", cfg.Type)
+	}
+	return cfg.Match, cfg.Match.Compile()
+}
+
+type addProcessor struct {
+	properties map[string]string
+}
+
+func (p *addProcessor) Process(entry *Entry) ([]*Entry, error) {
+	for name, value := range p.properties {
+		entry.Trace.Properties[name] = value
+	}
+	return []*Entry{entry}, nil
+}
+
+type renameProcessor struct {
+	renames map[string]string
+}
+
+func (p *renameProcessor) Process(entry *Entry) ([]*Entry, error) {
+	trc := entry.Trace
+	for from, to := range p.renames {
+		if value, ok := trc.Properties[from]; ok {
+			delete(trc.Properties, from)
+			trc.Properties[to] = value
+		}
+		if value, ok := trc.Metrics[from]; ok {
+			delete(trc.Metrics, from)
+			trc.Metrics[to] = value
+		}
+	}
+	return []*Entry{entry}, nil
+}
+
+type promoteProcessor struct {
+	fields []string
+}
+
+func (p *promoteProcessor) Process(entry *Entry) ([]*Entry, error) {
+	trc := entry.Trace
+	for _, name := range p.fields {
+		if value, ok := trc.Properties[name]; ok {
+			f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err == nil {
+				delete(trc.Properties, name)
+				trc.Metrics[name] = f
+			}
+		}
+	}
+	return []*Entry{entry}, nil
+}
+
+type jsonProcessor struct {
+	field string
+}
+
+func (p *jsonProcessor) Process(entry *Entry) ([]*Entry, error) {
+	value := getField(entry.Trace, p.field)
+	start := strings.Index(value, "{")
+	end := strings.LastIndex(value, "}")
+	if start < 0 || end < start {
+		return []*Entry{entry}, nil
+	}
+
+	var jsonMap map[string]interface{}
+	if json.Unmarshal([]byte(value[start:end+1]), &jsonMap) == nil {
+		populatePropertiesAndMetrics(jsonMap, entry.Trace)
+	}
+	return []*Entry{entry}, nil
+}
+
+type regexProcessor struct {
+	field string
+	re    *regexp.Regexp
+}
+
+func (p *regexProcessor) Process(entry *Entry) ([]*Entry, error) {
+	groups := p.re.FindStringSubmatch(getField(entry.Trace, p.field))
+	for i, name := range p.re.SubexpNames() {
+		if name != "" && i < len(groups) {
+			entry.Trace.Properties[name] = groups[i]
+		}
+	}
+	return []*Entry{entry}, nil
+}
+
+type dropProcessor struct {
+	match *Match
+}
+
+func (p *dropProcessor) Process(entry *Entry) ([]*Entry, error) {
+	if p.match.IsMatch(entry.Trace) {
+		return nil, nil
+	}
+	return []*Entry{entry}, nil
+}
+
+type splitProcessor struct {
+	re *regexp.Regexp
+}
+
+func (p *splitProcessor) Process(entry *Entry) ([]*Entry, error) {
+	parts := p.re.Split(entry.Trace.Message, -1)
+	entries := make([]*Entry, 0, len(parts))
+	for _, part := range parts {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		trc := entry.Trace.Clone()
+		trc.Message = part
+		entries = append(entries, &Entry{Trace: trc, Payload: entry.Payload})
+	}
+	return entries, nil
+}
+
+type routeProcessor struct {
+	match    *Match
+	pipeline *Pipeline
+}
+
+func (p *routeProcessor) Process(entry *Entry) ([]*Entry, error) {
+	if !p.match.IsMatch(entry.Trace) {
+		return []*Entry{entry}, nil
+	}
+	return p.pipeline.Process(entry)
+}
+
+type computeProcessor struct {
+	assignments []*Assignment
+}
+
+func (p *computeProcessor) Process(entry *Entry) ([]*Entry, error) {
+	for _, assignment := range p.assignments {
+		assignment.Apply(entry.Trace)
+	}
+	return []*Entry{entry}, nil
+}
+
+func getField(trc *Trace, field string) string {
+	if field == "Message" {
+		return trc.Message
+	}
+	return trc.Properties[field]
+}
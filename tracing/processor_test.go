@@ -0,0 +1,102 @@
+package tracing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getEntry(message string) *Entry {
+	return &Entry{Trace: NewTrace(time.Now(), message, "12345", "info"), Payload: message}
+}
+
+func buildPipeline(t *testing.T, configs ...*ProcessorConfig) *Pipeline {
+	processors, err := BuildProcessors(configs)
+	assert.Nil(t, err)
+	return NewPipeline(processors...)
+}
+
+func Test_pipeline_enriches_and_transforms(t *testing.T) {
+	pipeline := buildPipeline(t,
+		&ProcessorConfig{Type: ProcessorAdd, Properties: map[string]string{"env": "local"}},
+		&ProcessorConfig{Type: ProcessorRegex, Pattern: `took (?P<took>\d+)ms on (?P<host>\w+)`},
+		&ProcessorConfig{Type: ProcessorPromote, Fields: []string{"took"}},
+		&ProcessorConfig{Type: ProcessorRename, Renames: map[string]string{"took": "latency_ms", "host": "machine"}},
+	)
+
+	entries, err := pipeline.Process(getEntry("request took 42ms on box1"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+	trc := entries[0].Trace
+	assert.Equal(t, "local", trc.Properties["env"])
+	assert.Equal(t, "box1", trc.Properties["machine"])
+	assert.Equal(t, 42.0, trc.Metrics["latency_ms"])
+	assert.NotContains(t, trc.Properties, "took")
+}
+
+func Test_pipeline_parses_embedded_json(t *testing.T) {
+	pipeline := buildPipeline(t, &ProcessorConfig{Type: ProcessorJson})
+	entries, err := pipeline.Process(getEntry(`order placed {"orderId":"A1","total":12.5}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "A1", entries[0].Trace.Properties["orderId"])
+	assert.Equal(t, 12.5, entries[0].Trace.Metrics["total"])
+}
+
+func Test_pipeline_drops_and_splits(t *testing.T) {
+	pipeline := buildPipeline(t,
+		&ProcessorConfig{Type: ProcessorDrop, Match: &Match{MessagePattern: "^heartbeat"}},
+		&ProcessorConfig{Type: ProcessorSplit, Pattern: `\n`},
+	)
+
+	entries, err := pipeline.Process(getEntry("heartbeat"))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(entries))
+
+	original := getEntry("one\ntwo\n")
+	entries, err = pipeline.Process(original)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, "two", entries[1].Trace.Message)
+	assert.NotEqual(t, entries[0].Trace.TraceId, entries[1].Trace.TraceId)
+	assert.Equal(t, original.Trace.CorrelationId, entries[1].Trace.CorrelationId)
+}
+
+func Test_pipeline_routes_matching_entries(t *testing.T) {
+	pipeline := buildPipeline(t, &ProcessorConfig{
+		Type:  ProcessorRoute,
+		Match: &Match{MessagePattern: "^payment"},
+		Processors: []*ProcessorConfig{
+			{Type: ProcessorAdd, Properties: map[string]string{"team": "payments"}},
+		},
+	})
+
+	entries, _ := pipeline.Process(getEntry("payment done"))
+	assert.Equal(t, "payments", entries[0].Trace.Properties["team"])
+	entries, _ = pipeline.Process(getEntry("hello"))
+	assert.NotContains(t, entries[0].Trace.Properties, "team")
+}
+
+func Test_pipeline_ingest_stores_output(t *testing.T) {
+	store, _ := NewInMemoryStore(EmptyConfig())
+	filters := NewFilterRules()
+	_, _ = filters.Add(&FilterRule{Action: FilterExclude, Match: Match{MessagePattern: "noise"}})
+	pipeline := NewPipeline(filters)
+
+	kept := getEntry("signal")
+	assert.Nil(t, pipeline.Ingest(store, kept))
+	dropped := getEntry("noise")
+	assert.Nil(t, pipeline.Ingest(store, dropped))
+
+	trc, _ := store.GetById(kept.Trace.TraceId)
+	assert.NotNil(t, trc)
+	trc, _ = store.GetById(dropped.Trace.TraceId)
+	assert.Nil(t, trc)
+}
+
+func Test_unknown_processor(t *testing.T) {
+	_, err := BuildProcessor(&ProcessorConfig{Type: "teleport"})
+	assert.NotNil(t, err)
+	_, err = BuildProcessor(&ProcessorConfig{Type: ProcessorRegex, Pattern: "no groups"})
+	assert.NotNil(t, err)
+}
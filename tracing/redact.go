@@ -0,0 +1,254 @@
+package tracing
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+const (
+	RedactMask = "mask" // replaces with asterisks
+	RedactHash = "hash" // replaces with a short hash so equal values can still be correlated
+	RedactDrop = "drop" // removes the value altogether
+
+	redactedMask = "****"
+)
+
+// well-known patterns that can be referred to by name instead of a regular expression
+var redactionPresets = map[string]string{
+	"email":  `[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`,
+	"card":   `\b(?:\d[ \-]?){12,18}\d\b`,
+	"bearer": `(?i)bearer\s+[A-Za-z0-9\-._~+/]+=*`,
+}
+
+// checks on what a preset matched, so e.g. timestamps and ids of the same length are not taken for card numbers
+var redactionChecks = map[string]func(match string) bool{
+	"card": isLuhnValid,
+}
+
+// RedactionRule masks values of the named properties and/or matches of a pattern.
+// Named properties that are metrics are removed regardless of the mode.
+type RedactionRule struct {
+	Properties []string
+	Pattern    string // a regular expression, or one of the preset names: email, card or bearer
+	Mode       string // mask, hash or drop
+}
+
+type Redactor struct {
+	rules    []*RedactionRule
+	patterns []*regexp.Regexp
+	checks   []func(match string) bool
+}
+
+func NewRedactor(rules []*RedactionRule) (*Redactor, error) {
+	redactor := &Redactor{
+		rules:    rules,
+		patterns: make([]*regexp.Regexp, len(rules)),
+		checks:   make([]func(match string) bool, len(rules)),
+	}
+
+	for i, rule := range rules {
+		if rule.Mode != RedactMask && rule.Mode != RedactHash && rule.Mode != RedactDrop {
+			return nil, errors.New("redaction mode must be mask, hash or drop")
+		}
+
+		pattern := rule.Pattern
+		if preset, ok := redactionPresets[pattern]; ok {
+			pattern = preset
+			redactor.checks[i] = redactionChecks[rule.Pattern]
+		}
+
+		var err error
+		redactor.patterns[i], err = compileOptional(pattern)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return redactor, nil
+}
+
+// the Luhn checksum all card numbers carry in their last digit
+func isLuhnValid(match string) bool {
+	sum, digits := 0, 0
+	for i := len(match) - 1; i >= 0; i-- {
+		if match[i] < '0' || match[i] > '9' {
+			continue
+		}
+
+		digit := int(match[i] - '0')
+		if digits%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		digits++
+	}
+	return digits > 0 && sum%10 == 0
+}
+
+// LoadRedactor reads a JSON array of rules from a file
+func LoadRedactor(path string) (*Redactor, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []*RedactionRule
+	err = json.Unmarshal(data, &rules)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRedactor(rules)
+}
+
+// Redact masks the trace in place and returns the redacted original payload
+func (redactor *Redactor) Redact(trc *Trace, payload string) string {
+	for _, rule := range redactor.rules {
+		for _, name := range rule.Properties {
+			delete(trc.Metrics, name)
+			if value, ok := trc.Properties[name]; ok {
+				if rule.Mode == RedactDrop {
+					delete(trc.Properties, name)
+				} else {
+					trc.Properties[name] = redactValue(value, rule.Mode)
+				}
+			}
+		}
+	}
+
+	trc.Message = redactor.redactPatterns(trc.Message)
+	for name, value := range trc.Properties {
+		trc.Properties[name] = redactor.redactPatterns(value)
+	}
+
+	return redactor.redactPayload(payload)
+}
+
+// replaces what the patterns match in the text
+func (redactor *Redactor) redactPatterns(text string) string {
+	for i, rule := range redactor.rules {
+		re := redactor.patterns[i]
+		if re == nil {
+			continue
+		}
+
+		check := redactor.checks[i]
+		text = re.ReplaceAllStringFunc(text, func(match string) string {
+			if check != nil && !check(match) {
+				return match
+			}
+			return redactValue(match, rule.Mode)
+		})
+	}
+	return text
+}
+
+// a JSON payload is redacted value by value so it stays valid JSON, others as text
+func (redactor *Redactor) redactPayload(payload string) string {
+	if strings.HasPrefix(strings.TrimSpace(payload), "{") {
+		var jsonMap map[string]interface{}
+		decoder := json.NewDecoder(strings.NewReader(payload))
+		decoder.UseNumber() // numbers are written back exactly as they were
+		if decoder.Decode(&jsonMap) == nil {
+			redacted, changed := redactor.redactJson(jsonMap)
+			if !changed {
+				return payload
+			}
+
+			var buffer strings.Builder
+			encoder := json.NewEncoder(&buffer)
+			encoder.SetEscapeHTML(false)
+			if encoder.Encode(redacted) != nil {
+				return payload
+			}
+			return strings.TrimSuffix(buffer.String(), "\n")
+		}
+	}
+
+	return redactor.redactPatterns(payload)
+}
+
+// redacts named properties at any depth and pattern matches in strings and numbers
+func (redactor *Redactor) redactJson(value interface{}) (interface{}, bool) {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		changed := false
+		for key, item := range typed {
+			if rule := redactor.ruleNaming(key); rule != nil {
+				changed = true
+				s, isString := item.(string)
+				if rule.Mode == RedactDrop || !isString {
+					delete(typed, key)
+				} else {
+					typed[key] = redactValue(s, rule.Mode)
+				}
+				continue
+			}
+
+			redacted, itemChanged := redactor.redactJson(item)
+			if itemChanged {
+				typed[key] = redacted
+				changed = true
+			}
+		}
+		return typed, changed
+	case []interface{}:
+		changed := false
+		for i, item := range typed {
+			redacted, itemChanged := redactor.redactJson(item)
+			if itemChanged {
+				typed[i] = redacted
+				changed = true
+			}
+		}
+		return typed, changed
+	case string:
+		redacted := redactor.redactPatterns(typed)
+		return redacted, redacted != typed
+	case json.Number:
+		redacted := redactor.redactPatterns(typed.String())
+		if redacted == typed.String() {
+			return typed, false
+		}
+		return redacted, true
+	}
+
+	return value, false
+}
+
+// the first rule naming the property
+func (redactor *Redactor) ruleNaming(name string) *RedactionRule {
+	for _, rule := range redactor.rules {
+		if slices.Contains(rule.Properties, name) {
+			return rule
+		}
+	}
+	return nil
+}
+
+func redactValue(value string, mode string) string {
+	switch mode {
+	case RedactHash:
+		sum := sha256.Sum256([]byte(value))
+		return "sha256:" + hex.EncodeToString(sum[:6])
+	case RedactDrop:
+		return ""
+	default:
+		return redactedMask
+	}
+}
+
+func (redactor *Redactor) Process(entry *Entry) ([]*Entry, error) {
+	entry.Payload = redactor.Redact(entry.Trace, entry.Payload)
+	return []*Entry{entry}, nil
+}
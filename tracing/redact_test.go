@@ -0,0 +1,109 @@
+package tracing
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_redact_named_properties(t *testing.T) {
+	redactor, err := NewRedactor([]*RedactionRule{
+		{Properties: []string{"password"}, Mode: RedactMask},
+		{Properties: []string{"ssn", "salary"}, Mode: RedactDrop},
+	})
+	assert.Nil(t, err)
+
+	trc := NewTrace(time.Now(), "login", "12345", "info")
+	trc.Properties["password"] = "hunter2"
+	trc.Properties["ssn"] = "123-45-6789"
+	trc.Metrics["salary"] = 1000
+	payload := redactor.Redact(trc, `{"message":"login","password":"hunter2","ssn":"123-45-6789","salary":1000}`)
+
+	assert.Equal(t, "****", trc.Properties["password"])
+	assert.NotContains(t, trc.Properties, "ssn")
+	assert.NotContains(t, trc.Metrics, "salary")
+	assert.NotContains(t, payload, "hunter2")
+	assert.NotContains(t, payload, "123-45-6789")
+	assert.NotContains(t, payload, "salary")
+}
+
+func Test_redact_presets_in_message_properties_and_payload(t *testing.T) {
+	redactor, err := NewRedactor([]*RedactionRule{
+		{Pattern: "email", Mode: RedactHash},
+		{Pattern: "card", Mode: RedactMask},
+		{Pattern: "bearer", Mode: RedactDrop},
+	})
+	assert.Nil(t, err)
+
+	trc := NewTrace(time.Now(), "paid by jo@example.com with 4111 1111 1111 1111", "12345", "info")
+	trc.Properties["auth"] = "Bearer abc.def-ghi"
+	payload := redactor.Redact(trc, "paid by jo@example.com with 4111 1111 1111 1111 auth Bearer abc.def-ghi")
+
+	assert.True(t, strings.HasPrefix(trc.Message, "paid by sha256:"))
+	assert.True(t, strings.HasSuffix(trc.Message, "with ****"))
+	assert.Equal(t, "", trc.Properties["auth"])
+	assert.NotContains(t, payload, "jo@example.com")
+	assert.NotContains(t, payload, "4111")
+	assert.NotContains(t, payload, "abc.def")
+}
+
+func Test_redact_hash_is_stable(t *testing.T) {
+	assert.Equal(t, redactValue("jo@example.com", RedactHash), redactValue("jo@example.com", RedactHash))
+	assert.NotEqual(t, redactValue("jo@example.com", RedactHash), redactValue("al@example.com", RedactHash))
+}
+
+func Test_redact_bad_mode(t *testing.T) {
+	_, err := NewRedactor([]*RedactionRule{{Pattern: "email", Mode: "shred"}})
+	assert.NotNil(t, err)
+}
+
+func Test_redact_card_numbers_need_a_valid_checksum(t *testing.T) {
+	redactor, err := NewRedactor([]*RedactionRule{{Pattern: "card", Mode: RedactMask}})
+	assert.Nil(t, err)
+
+	trc := NewTrace(time.Now(), "order 1700000000000 paid with 4111-1111-1111-1111", "12345", "info")
+	payload := redactor.Redact(trc, `{"ts":1700000000000,"card":4111111111111111,"note":"4111 1111 1111 1111"}`)
+
+	assert.Equal(t, "order 1700000000000 paid with ****", trc.Message)
+	assert.Equal(t, `{"card":"****","note":"****","ts":1700000000000}`, payload)
+}
+
+func Test_redact_nested_payload_properties(t *testing.T) {
+	redactor, err := NewRedactor([]*RedactionRule{{Properties: []string{"password"}, Mode: RedactMask}})
+	assert.Nil(t, err)
+
+	trc := NewTrace(time.Now(), "login", "12345", "info")
+	payload := redactor.Redact(trc, `{"message":"login","user":{"name":"jo","password":"hunter2"},"tries":[{"password":"letmein"}]}`)
+
+	assert.NotContains(t, payload, "hunter2")
+	assert.NotContains(t, payload, "letmein")
+	assert.Contains(t, payload, `"name":"jo"`)
+
+	// left as it was when there is nothing to redact
+	original := `{"message": "login", "tries": 3}`
+	assert.Equal(t, original, redactor.Redact(trc, original))
+}
+
+func Test_redact_what_processors_extract(t *testing.T) {
+	redactor, err := NewRedactor([]*RedactionRule{
+		{Properties: []string{"password"}, Mode: RedactMask},
+		{Pattern: "email", Mode: RedactMask},
+	})
+	assert.Nil(t, err)
+	processors, err := BuildProcessors([]*ProcessorConfig{
+		{Type: ProcessorRegex, Pattern: `password=(?P<password>\S+)`},
+		{Type: ProcessorJson},
+	})
+	assert.Nil(t, err)
+
+	pipeline := NewPipeline(RedactedStages(redactor, processors)...)
+	trc := NewTrace(time.Now(), `login password=hunter2 {"contact":"jo@example.com"}`, "12345", "info")
+	entries, err := pipeline.Process(&Entry{Trace: trc})
+	assert.Nil(t, err)
+
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, "****", entries[0].Trace.Properties["password"])
+	assert.Equal(t, "****", entries[0].Trace.Properties["contact"])
+}
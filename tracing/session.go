@@ -0,0 +1,195 @@
+package tracing
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Session struct {
+	Id      string
+	Name    string
+	Started time.Time
+	Stopped *time.Time // null while the session is capturing
+}
+
+// Sessions tags every incoming trace not already in a session with the id of the running capture session, if any.
+// Only one session captures at a time and a stopped session is frozen.
+type Sessions struct {
+	lock   sync.RWMutex
+	store  TraceStore
+	active *Session
+}
+
+var ErrSessionNotFound = errors.New("session not found")
+var ErrSessionNameEmpty = errors.New("session name cannot be empty")
+
+func NewSessions(store TraceStore) (*Sessions, error) {
+	sessions := &Sessions{
+		store: store,
+	}
+
+	// a session left running is picked up again
+	all, err := store.ListSessions()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, session := range all {
+		if session.Stopped == nil {
+			sessions.active = session
+		}
+	}
+
+	return sessions, nil
+}
+
+// Start stops the running session, if any, and starts capturing into a new one
+func (sessions *Sessions) Start(name string) (*Session, error) {
+	sessions.lock.Lock()
+	defer sessions.lock.Unlock()
+	if sessions.active != nil {
+		_, err := sessions.stopActive()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	session := &Session{
+		Id:      uuid.New().String(),
+		Name:    name,
+		Started: time.Now().UTC(),
+	}
+
+	if session.Name == "" {
+		session.Name = session.Started.Format(time.RFC3339)
+	}
+
+	err := sessions.store.SaveSession(session)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions.active = session
+	return session, nil
+}
+
+// Create adds a frozen session, e.g. to import traces into, leaving the running session as it is
+func (sessions *Sessions) Create(name string) (*Session, error) {
+	now := time.Now().UTC()
+	session := &Session{
+		Id:      uuid.New().String(),
+		Name:    name,
+		Started: now,
+		Stopped: &now,
+	}
+
+	if session.Name == "" {
+		session.Name = now.Format(time.RFC3339)
+	}
+
+	err := sessions.store.SaveSession(session)
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// Stop freezes the running session and returns null if none was running
+func (sessions *Sessions) Stop() (*Session, error) {
+	sessions.lock.Lock()
+	defer sessions.lock.Unlock()
+	if sessions.active == nil {
+		return nil, nil
+	}
+
+	return sessions.stopActive()
+}
+
+// sessions are replaced rather than changed in place as readers may hold on to them
+func (sessions *Sessions) stopActive() (*Session, error) {
+	stopped := *sessions.active
+	now := time.Now().UTC()
+	stopped.Stopped = &now
+	err := sessions.store.SaveSession(&stopped)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions.active = nil
+	return &stopped, nil
+}
+
+func (sessions *Sessions) Rename(id string, name string) (*Session, error) {
+	if strings.TrimSpace(name) == "" {
+		return nil, ErrSessionNameEmpty
+	}
+
+	sessions.lock.Lock()
+	defer sessions.lock.Unlock()
+	session, err := sessions.store.GetSession(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if session == nil {
+		return nil, ErrSessionNotFound
+	}
+
+	renamed := *session
+	renamed.Name = name
+	err = sessions.store.SaveSession(&renamed)
+	if err != nil {
+		return nil, err
+	}
+
+	if sessions.active != nil && sessions.active.Id == id {
+		sessions.active = &renamed
+	}
+
+	return &renamed, nil
+}
+
+// Delete removes the session along with its traces
+func (sessions *Sessions) Delete(id string) error {
+	sessions.lock.Lock()
+	defer sessions.lock.Unlock()
+	deleted, err := sessions.store.DeleteSession(id)
+	if err != nil {
+		return err
+	}
+
+	if !deleted {
+		return ErrSessionNotFound
+	}
+
+	if sessions.active != nil && sessions.active.Id == id {
+		sessions.active = nil
+	}
+
+	return nil
+}
+
+func (sessions *Sessions) List() ([]*Session, error) {
+	return sessions.store.ListSessions()
+}
+
+// Active returns the running session or null
+func (sessions *Sessions) Active() *Session {
+	sessions.lock.RLock()
+	defer sessions.lock.RUnlock()
+	return sessions.active
+}
+
+func (sessions *Sessions) Process(entry *Entry) ([]*Entry, error) {
+	sessions.lock.RLock()
+	defer sessions.lock.RUnlock()
+	if sessions.active != nil && entry.Trace.SessionId == "" {
+		entry.Trace.SessionId = sessions.active.Id
+	}
+	return []*Entry{entry}, nil
+}
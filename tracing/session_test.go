@@ -0,0 +1,77 @@
+package tracing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ingestMessage(t *testing.T, store TraceStore, sessions *Sessions, message string) *Trace {
+	trc := NewTrace(time.Now(), message, "12345", "info")
+	assert.Nil(t, NewPipeline(sessions).Ingest(store, &Entry{Trace: trc}))
+	return trc
+}
+
+func Test_session_tags_traces_while_capturing(t *testing.T) {
+	store, _ := NewInMemoryStore(EmptyConfig())
+	sessions, err := NewSessions(store)
+	assert.Nil(t, err)
+
+	before := ingestMessage(t, store, sessions, "before")
+	first, err := sessions.Start("repro 1")
+	assert.Nil(t, err)
+	during := ingestMessage(t, store, sessions, "during")
+	stopped, err := sessions.Stop()
+	assert.Nil(t, err)
+	assert.NotNil(t, stopped.Stopped)
+	after := ingestMessage(t, store, sessions, "after")
+
+	assert.Equal(t, "", before.SessionId)
+	assert.Equal(t, first.Id, during.SessionId)
+	assert.Equal(t, "", after.SessionId)
+
+	traces, err := store.ListBySessionId(first.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(traces))
+	assert.Equal(t, during.TraceId, traces[0].TraceId)
+}
+
+func Test_starting_a_session_stops_the_running_one(t *testing.T) {
+	store, _ := NewInMemoryStore(EmptyConfig())
+	sessions, _ := NewSessions(store)
+	first, _ := sessions.Start("repro 1")
+	second, _ := sessions.Start("repro 2")
+
+	assert.Equal(t, second.Id, sessions.Active().Id)
+	saved, _ := store.GetSession(first.Id)
+	assert.NotNil(t, saved.Stopped)
+
+	all, _ := sessions.List()
+	assert.Equal(t, 2, len(all))
+}
+
+func Test_rename_and_delete_session(t *testing.T) {
+	store, _ := NewInMemoryStore(EmptyConfig())
+	sessions, _ := NewSessions(store)
+	session, _ := sessions.Start("repro")
+	trc := ingestMessage(t, store, sessions, "during")
+
+	renamed, err := sessions.Rename(session.Id, "the one that failed")
+	assert.Nil(t, err)
+	assert.Equal(t, "the one that failed", renamed.Name)
+	assert.Equal(t, "the one that failed", sessions.Active().Name)
+
+	_, err = sessions.Rename(session.Id, " ")
+	assert.Equal(t, ErrSessionNameEmpty, err)
+	assert.Equal(t, "the one that failed", sessions.Active().Name)
+
+	assert.Nil(t, sessions.Delete(session.Id))
+	assert.Nil(t, sessions.Active())
+	gone, _ := store.GetById(trc.TraceId)
+	assert.Nil(t, gone)
+
+	assert.Equal(t, ErrSessionNotFound, sessions.Delete(session.Id))
+	_, err = sessions.Rename(session.Id, "again")
+	assert.Equal(t, ErrSessionNotFound, err)
+}
@@ -6,4 +6,21 @@ type TraceStore interface {
 	Store(trace *Trace, originalPayload string) error
 	GetById(id string) (*Trace, error)
 	ListByTimeRange(n int, from, to *time.Time, exclusive bool) ([]*Trace, error)
+	// returns up to n traces after the cursor, or before it when going backward, within the time range
+	ListPage(n int, from, to *time.Time, match *Match, cursor *Cursor, backward bool) (*Page, error)
+	ListByCorrelationId(corrId string) ([]*Trace, error)
+	// visits traces in time order until fn returns false
+	Walk(from, to *time.Time, match *Match, fn func(trc *Trace) bool) error
+	// removes all traces, keeping rules and sessions
+	Clear() error
+
+	SaveHighlightRule(rule *HighlightRule) error
+	DeleteHighlightRule(id string) (bool, error)
+	ListHighlightRules() ([]*HighlightRule, error)
+
+	SaveSession(session *Session) error
+	GetSession(id string) (*Session, error)
+	DeleteSession(id string) (bool, error) // also deletes the traces of the session
+	ListSessions() ([]*Session, error)
+	ListBySessionId(sessionId string) ([]*Trace, error)
 }
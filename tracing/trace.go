@@ -12,9 +12,15 @@ type Trace struct {
 	Timestamp     time.Time
 	Message       string
 	CorrelationId string
+	SpanId        string
+	ParentSpanId  string
 	Level         string
 	Metrics       map[string]float64
 	Properties    map[string]string
+	Source        string   // where the trace was received from
+	Highlights    []string // ids of the highlight rules the trace matched at ingest
+	SessionId     string   // the capture session that was running when the trace arrived
+	PatternId     string   // the message template the trace was clustered into
 	TimeIndex     string
 }
 
@@ -27,7 +33,26 @@ func NewTrace(ts time.Time, message string, corrId string, level string) *Trace
 		Level:         level,
 		Metrics:       make(map[string]float64),
 		Properties:    make(map[string]string),
+		Highlights:    make([]string, 0),
 		TimeIndex:     strconv.FormatInt(ts.UnixMicro(), 10),
 	}
 
 }
+
+// Clone returns a deep copy of the trace under a new id
+func (trc *Trace) Clone() *Trace {
+	clone := NewTrace(trc.Timestamp, trc.Message, trc.CorrelationId, trc.Level)
+	clone.SpanId = trc.SpanId
+	clone.ParentSpanId = trc.ParentSpanId
+	clone.Source = trc.Source
+	clone.SessionId = trc.SessionId
+	clone.PatternId = trc.PatternId
+	clone.Highlights = append(clone.Highlights, trc.Highlights...)
+	for name, value := range trc.Metrics {
+		clone.Metrics[name] = value
+	}
+	for name, value := range trc.Properties {
+		clone.Properties[name] = value
+	}
+	return clone
+}
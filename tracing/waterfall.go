@@ -0,0 +1,163 @@
+package tracing
+
+import (
+	"sort"
+	"time"
+)
+
+// metrics that carry the explicit duration of a span, with their unit
+var durationMetrics = []struct {
+	name string
+	unit time.Duration
+}{
+	{"duration_ms", time.Millisecond},
+	{"durationMs", time.Millisecond},
+	{"DurationMs", time.Millisecond},
+	{"elapsed_ms", time.Millisecond},
+	{"elapsedMs", time.Millisecond},
+	{"duration_us", time.Microsecond},
+}
+
+type Span struct {
+	SpanId       string
+	ParentSpanId string
+	Name         string
+	Level        string
+	Start        time.Time
+	End          time.Time
+	OffsetMs     float64 // from the start of the waterfall
+	DurationMs   float64
+	Depth        int
+	TraceIds     []string
+	children     []*Span
+}
+
+type Waterfall struct {
+	CorrelationId string
+	Start         time.Time
+	End           time.Time
+	DurationMs    float64
+	Spans         []*Span  // depth-first, parents before their children
+	Unspanned     []string // ids of traces without a span id
+}
+
+// BuildWaterfall rebuilds the span tree of a correlation id from its traces.
+// A span starts at its first event and ends at its last one, unless an event carries
+// an explicit duration metric in which case it runs at least until timestamp + duration.
+func BuildWaterfall(corrId string, traces []*Trace) *Waterfall {
+	waterfall := &Waterfall{
+		CorrelationId: corrId,
+		Spans:         make([]*Span, 0),
+		Unspanned:     make([]string, 0),
+	}
+
+	spans := make(map[string]*Span)
+	order := make([]*Span, 0)
+	for _, trc := range traces {
+		if trc.SpanId == "" {
+			waterfall.Unspanned = append(waterfall.Unspanned, trc.TraceId)
+			continue
+		}
+
+		span, ok := spans[trc.SpanId]
+		if !ok {
+			span = &Span{
+				SpanId:   trc.SpanId,
+				Name:     trc.Message,
+				Level:    trc.Level,
+				Start:    trc.Timestamp,
+				End:      trc.Timestamp,
+				TraceIds: make([]string, 0),
+			}
+			spans[trc.SpanId] = span
+			order = append(order, span)
+		}
+
+		if span.ParentSpanId == "" {
+			span.ParentSpanId = trc.ParentSpanId
+		}
+
+		if trc.Timestamp.Before(span.Start) {
+			span.Start = trc.Timestamp
+			span.Name = trc.Message
+		}
+
+		end := trc.Timestamp
+		if duration, ok := getDuration(trc); ok {
+			end = trc.Timestamp.Add(duration)
+		}
+
+		if end.After(span.End) {
+			span.End = end
+		}
+
+		span.TraceIds = append(span.TraceIds, trc.TraceId)
+	}
+
+	roots := make([]*Span, 0)
+	for _, span := range order {
+		parent, ok := spans[span.ParentSpanId]
+		if ok && parent != span {
+			parent.children = append(parent.children, span)
+		} else {
+			roots = append(roots, span)
+		}
+	}
+
+	for _, span := range order {
+		if waterfall.Start.IsZero() || span.Start.Before(waterfall.Start) {
+			waterfall.Start = span.Start
+		}
+		if span.End.After(waterfall.End) {
+			waterfall.End = span.End
+		}
+	}
+
+	waterfall.DurationMs = toMs(waterfall.End.Sub(waterfall.Start))
+	visited := make(map[*Span]bool)
+	var walk func(spans []*Span, depth int)
+	walk = func(spans []*Span, depth int) {
+		sortSpans(spans)
+		for _, span := range spans {
+			if visited[span] {
+				continue // cycles in parent ids are cut here
+			}
+			visited[span] = true
+			span.Depth = depth
+			span.OffsetMs = toMs(span.Start.Sub(waterfall.Start))
+			span.DurationMs = toMs(span.End.Sub(span.Start))
+			waterfall.Spans = append(waterfall.Spans, span)
+			walk(span.children, depth+1)
+		}
+	}
+
+	walk(roots, 0)
+
+	// spans only reachable through a cycle of parent ids
+	for _, span := range order {
+		if !visited[span] {
+			walk([]*Span{span}, 0)
+		}
+	}
+
+	return waterfall
+}
+
+func getDuration(trc *Trace) (time.Duration, bool) {
+	for _, metric := range durationMetrics {
+		if value, ok := trc.Metrics[metric.name]; ok {
+			return time.Duration(value * float64(metric.unit)), true
+		}
+	}
+	return 0, false
+}
+
+func sortSpans(spans []*Span) {
+	sort.SliceStable(spans, func(i, j int) bool {
+		return spans[i].Start.Before(spans[j].Start)
+	})
+}
+
+func toMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
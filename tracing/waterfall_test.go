@@ -0,0 +1,70 @@
+package tracing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getSpanTrace(ts time.Time, message, spanId, parentSpanId string) *Trace {
+	trc := NewTrace(ts, message, "12345", "info")
+	trc.SpanId = spanId
+	trc.ParentSpanId = parentSpanId
+	return trc
+}
+
+func Test_waterfall_from_begin_end_events(t *testing.T) {
+	start := time.Date(2022, 4, 4, 10, 0, 0, 0, time.UTC)
+	traces := []*Trace{
+		getSpanTrace(start, "request begin", "a", ""),
+		getSpanTrace(start.Add(10*time.Millisecond), "db begin", "b", "a"),
+		getSpanTrace(start.Add(30*time.Millisecond), "db end", "b", "a"),
+		getSpanTrace(start.Add(50*time.Millisecond), "request end", "a", ""),
+		NewTrace(start.Add(20*time.Millisecond), "no span", "12345", "info"),
+	}
+
+	waterfall := BuildWaterfall("12345", traces)
+	assert.Equal(t, 2, len(waterfall.Spans))
+	assert.Equal(t, 1, len(waterfall.Unspanned))
+	assert.Equal(t, 50.0, waterfall.DurationMs)
+
+	root := waterfall.Spans[0]
+	assert.Equal(t, "a", root.SpanId)
+	assert.Equal(t, "request begin", root.Name)
+	assert.Equal(t, 0, root.Depth)
+	assert.Equal(t, 50.0, root.DurationMs)
+
+	child := waterfall.Spans[1]
+	assert.Equal(t, "b", child.SpanId)
+	assert.Equal(t, 1, child.Depth)
+	assert.Equal(t, 10.0, child.OffsetMs)
+	assert.Equal(t, 20.0, child.DurationMs)
+	assert.Equal(t, 2, len(child.TraceIds))
+}
+
+func Test_waterfall_from_duration_metrics(t *testing.T) {
+	start := time.Date(2022, 4, 4, 10, 0, 0, 0, time.UTC)
+	root := getSpanTrace(start, "request", "a", "")
+	root.Metrics["duration_ms"] = 100
+	child := getSpanTrace(start.Add(5*time.Millisecond), "call", "b", "a")
+	child.Metrics["duration_us"] = 2500
+
+	waterfall := BuildWaterfall("12345", []*Trace{child, root})
+	assert.Equal(t, 2, len(waterfall.Spans))
+	assert.Equal(t, "a", waterfall.Spans[0].SpanId)
+	assert.Equal(t, 100.0, waterfall.Spans[0].DurationMs)
+	assert.Equal(t, 2.5, waterfall.Spans[1].DurationMs)
+	assert.Equal(t, 5.0, waterfall.Spans[1].OffsetMs)
+}
+
+func Test_waterfall_survives_parent_cycles(t *testing.T) {
+	start := time.Date(2022, 4, 4, 10, 0, 0, 0, time.UTC)
+	traces := []*Trace{
+		getSpanTrace(start, "one", "a", "b"),
+		getSpanTrace(start, "two", "b", "a"),
+	}
+
+	waterfall := BuildWaterfall("12345", traces)
+	assert.Equal(t, 2, len(waterfall.Spans))
+}
@@ -0,0 +1,109 @@
+package tracing
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+type ZipkinEndpoint struct {
+	ServiceName string `json:"serviceName"`
+	Ipv4        string `json:"ipv4"`
+	Ipv6        string `json:"ipv6"`
+	Port        int    `json:"port"`
+}
+
+type ZipkinAnnotation struct {
+	Timestamp int64  `json:"timestamp"`
+	Value     string `json:"value"`
+}
+
+// ZipkinSpan is a span in Zipkin v2 JSON format. Timestamp and duration are in microseconds.
+type ZipkinSpan struct {
+	TraceId        string             `json:"traceId"`
+	Id             string             `json:"id"`
+	ParentId       string             `json:"parentId"`
+	Name           string             `json:"name"`
+	Kind           string             `json:"kind"`
+	Timestamp      int64              `json:"timestamp"`
+	Duration       int64              `json:"duration"`
+	LocalEndpoint  *ZipkinEndpoint    `json:"localEndpoint"`
+	RemoteEndpoint *ZipkinEndpoint    `json:"remoteEndpoint"`
+	Annotations    []ZipkinAnnotation `json:"annotations"`
+	Tags           map[string]string  `json:"tags"`
+	Debug          bool               `json:"debug"`
+	Shared         bool               `json:"shared"`
+}
+
+// ParseZipkinSpans reads a Zipkin v2 JSON list of spans and returns each span
+// as a trace along with the span's own JSON as its original payload
+func ParseZipkinSpans(body []byte) ([]*Trace, []string, error) {
+	var rawSpans []json.RawMessage
+	err := json.Unmarshal(body, &rawSpans)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	traces := make([]*Trace, 0, len(rawSpans))
+	payloads := make([]string, 0, len(rawSpans))
+	for _, raw := range rawSpans {
+		var span ZipkinSpan
+		err = json.Unmarshal(raw, &span)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if span.TraceId == "" || span.Id == "" {
+			return nil, nil, errors.New("span is missing traceId or id")
+		}
+
+		traces = append(traces, span.ToTrace())
+		payloads = append(payloads, string(raw))
+	}
+
+	return traces, payloads, nil
+}
+
+func (span *ZipkinSpan) ToTrace() *Trace {
+	var timestamp time.Time
+	if span.Timestamp == 0 {
+		timestamp = time.Now().UTC()
+	} else {
+		timestamp = time.UnixMicro(span.Timestamp)
+	}
+
+	level := "info"
+	if _, ok := span.Tags["error"]; ok {
+		level = "error"
+	}
+
+	trc := NewTrace(timestamp, span.Name, span.TraceId, level)
+	trc.SpanId = span.Id
+	trc.ParentSpanId = span.ParentId
+	trc.Metrics["timestamp_us"] = float64(span.Timestamp)
+	if span.Duration > 0 {
+		trc.Metrics["duration_us"] = float64(span.Duration)
+	}
+
+	for key, value := range span.Tags {
+		trc.Properties[key] = value
+	}
+
+	if span.Kind != "" {
+		trc.Properties["kind"] = span.Kind
+	}
+
+	if span.LocalEndpoint != nil && span.LocalEndpoint.ServiceName != "" {
+		trc.Properties["serviceName"] = span.LocalEndpoint.ServiceName
+	}
+
+	if span.RemoteEndpoint != nil && span.RemoteEndpoint.ServiceName != "" {
+		trc.Properties["remoteServiceName"] = span.RemoteEndpoint.ServiceName
+	}
+
+	for _, annotation := range span.Annotations {
+		trc.Properties["annotation."+annotation.Value] = time.UnixMicro(annotation.Timestamp).UTC().Format(time.RFC3339Nano)
+	}
+
+	return trc
+}
@@ -0,0 +1,44 @@
+package tracing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parse_zipkin_spans(t *testing.T) {
+	body := `[{"traceId":"5af7183fb1d4cf5f","parentId":"6b221d5bc9e6496c","id":"352bff9a74ca9ad2","kind":"CLIENT",
+		"name":"get /api","timestamp":1556604172355737,"duration":1431,
+		"localEndpoint":{"serviceName":"backend","ipv4":"192.168.99.1","port":3306},
+		"remoteEndpoint":{"serviceName":"frontend"},
+		"tags":{"http.method":"GET","http.path":"/api"}}]`
+
+	traces, payloads, err := ParseZipkinSpans([]byte(body))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(traces))
+	assert.Equal(t, 1, len(payloads))
+
+	trc := traces[0]
+	assert.Equal(t, "get /api", trc.Message)
+	assert.Equal(t, "5af7183fb1d4cf5f", trc.CorrelationId)
+	assert.Equal(t, "352bff9a74ca9ad2", trc.SpanId)
+	assert.Equal(t, "6b221d5bc9e6496c", trc.ParentSpanId)
+	assert.Equal(t, int64(1556604172355737), trc.Timestamp.UnixMicro())
+	assert.Equal(t, 1431.0, trc.Metrics["duration_us"])
+	assert.Equal(t, 1556604172355737.0, trc.Metrics["timestamp_us"])
+	assert.Equal(t, "backend", trc.Properties["serviceName"])
+	assert.Equal(t, "frontend", trc.Properties["remoteServiceName"])
+	assert.Equal(t, "CLIENT", trc.Properties["kind"])
+	assert.Equal(t, "GET", trc.Properties["http.method"])
+	assert.Equal(t, "info", trc.Level)
+}
+
+func Test_parse_zipkin_spans_rejects_missing_ids(t *testing.T) {
+	_, _, err := ParseZipkinSpans([]byte(`[{"name":"get /api"}]`))
+	assert.NotNil(t, err)
+}
+
+func Test_parse_zipkin_spans_rejects_bad_json(t *testing.T) {
+	_, _, err := ParseZipkinSpans([]byte(`{"name":`))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,547 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aliostad/TraceView/tracing"
+	"golang.org/x/term"
+)
+
+const (
+	max_tui_traces   = 10000
+	tui_latest       = 100
+	tui_buffer       = 1000
+	tui_redraw_every = 100 * time.Millisecond
+	tui_repair_every = time.Second // redraws regardless, in case something else wrote to the terminal
+
+	tuiHelp = "j/k move  PgUp/PgDn page  g/G top/bottom  / search  space pause  c correlation  Esc back  q quit"
+)
+
+// tui is the full-screen terminal UI: a live list of traces over the detail of the selected one
+type tui struct {
+	source traceSource
+	out    *bufio.Writer
+
+	traces  []*tracing.Trace
+	seen    map[string]bool
+	pending []*tracing.Trace // arrived while paused
+	paused  bool
+
+	correlation       string // when set, the traces of this correlation id are shown instead of the live ones
+	correlationTraces []*tracing.Trace
+
+	search    string
+	searching bool
+	view      []*tracing.Trace // what the list shows
+	selected  int
+	top       int
+	follow    bool // keeps the latest trace selected as traces arrive
+	status    string
+
+	width  int
+	height int
+}
+
+func newTui(source traceSource) *tui {
+	return &tui{
+		source: source,
+		out:    bufio.NewWriter(os.Stdout),
+		traces: make([]*tracing.Trace, 0),
+		seen:   make(map[string]bool),
+		view:   make([]*tracing.Trace, 0),
+		follow: true,
+	}
+}
+
+// run takes over the terminal until the user quits
+func (ui *tui) run() error {
+	stdin := int(os.Stdin.Fd())
+	if !term.IsTerminal(stdin) || !isTerminal(os.Stdout) {
+		return errors.New("the terminal UI needs a terminal")
+	}
+
+	latest, err := ui.source.Latest(tui_latest)
+	if err != nil {
+		return err
+	}
+
+	since := time.Now().UTC()
+	if len(latest) > 0 {
+		since = latest[len(latest)-1].Timestamp
+	}
+	ui.add(latest...)
+
+	state, err := term.MakeRaw(stdin)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(stdin, state)
+
+	// alternate screen without a cursor, restored on the way out
+	fmt.Fprint(ui.out, "\x1b[?1049h\x1b[?25l")
+	defer func() {
+		fmt.Fprint(ui.out, "\x1b[?25h\x1b[?1049l")
+		ui.out.Flush()
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	incoming := make(chan *tracing.Trace, tui_buffer)
+	failed := make(chan error, 1)
+	go func() {
+		failed <- ui.source.Follow(ctx, since, incoming)
+	}()
+
+	keys := make(chan string, 16)
+	go readKeys(os.Stdin, keys)
+
+	redraw := time.NewTicker(tui_redraw_every)
+	defer redraw.Stop()
+	lastDrawn := time.Time{}
+	dirty := true
+	for {
+		select {
+		case key, ok := <-keys:
+			if !ok || !ui.handleKey(key) {
+				return nil
+			}
+			ui.render()
+			lastDrawn = time.Now()
+			dirty = false
+			continue
+		case trc := <-incoming:
+			ui.add(trc)
+			dirty = true
+			continue
+		case err := <-failed:
+			if err != nil {
+				ui.status = err.Error()
+				dirty = true
+			}
+		case <-redraw.C:
+		}
+
+		if dirty || time.Since(lastDrawn) >= tui_repair_every {
+			ui.render()
+			lastDrawn = time.Now()
+			dirty = false
+		}
+	}
+}
+
+// adds traces to the live list, or holds them back while paused
+func (ui *tui) add(traces ...*tracing.Trace) {
+	for _, trc := range traces {
+		if ui.seen[trc.TraceId] {
+			continue
+		}
+		ui.seen[trc.TraceId] = true
+		if ui.paused {
+			ui.pending = append(ui.pending, trc)
+		} else {
+			ui.traces = append(ui.traces, trc)
+		}
+	}
+
+	if len(ui.traces) > max_tui_traces {
+		dropped := len(ui.traces) - max_tui_traces
+		for _, trc := range ui.traces[:dropped] {
+			delete(ui.seen, trc.TraceId)
+		}
+		ui.traces = append([]*tracing.Trace{}, ui.traces[dropped:]...)
+	}
+
+	if ui.correlation == "" {
+		ui.refresh()
+	}
+}
+
+// rebuilds the view from the live list or the correlation, applying the search
+func (ui *tui) refresh() {
+	var selectedId string
+	if ui.selected < len(ui.view) {
+		selectedId = ui.view[ui.selected].TraceId
+	}
+
+	traces := ui.traces
+	if ui.correlation != "" {
+		traces = ui.correlationTraces
+	}
+
+	ui.view = make([]*tracing.Trace, 0, len(traces))
+	search := strings.ToLower(ui.search)
+	for _, trc := range traces {
+		if search == "" || matchesSearch(trc, search) {
+			ui.view = append(ui.view, trc)
+		}
+	}
+
+	ui.selected = len(ui.view) - 1
+	if !ui.follow {
+		for i, trc := range ui.view {
+			if trc.TraceId == selectedId {
+				ui.selected = i
+				break
+			}
+		}
+	}
+	if ui.selected < 0 {
+		ui.selected = 0
+	}
+}
+
+func matchesSearch(trc *tracing.Trace, search string) bool {
+	if strings.Contains(strings.ToLower(trc.Message), search) ||
+		strings.Contains(strings.ToLower(trc.CorrelationId), search) ||
+		strings.Contains(strings.ToLower(trc.Level), search) {
+		return true
+	}
+
+	for _, value := range trc.Properties {
+		if strings.Contains(strings.ToLower(value), search) {
+			return true
+		}
+	}
+	return false
+}
+
+// handles a key and returns false to quit
+func (ui *tui) handleKey(key string) bool {
+	if ui.searching {
+		switch key {
+		case "enter":
+			ui.searching = false
+		case "esc":
+			ui.searching = false
+			ui.search = ""
+		case "backspace":
+			if ui.search != "" {
+				runes := []rune(ui.search)
+				ui.search = string(runes[:len(runes)-1])
+			}
+		default:
+			if len([]rune(key)) == 1 {
+				ui.search += key
+			}
+		}
+		ui.refresh()
+		return true
+	}
+
+	page := ui.listHeight()
+	switch key {
+	case "q", "ctrl-c":
+		return false
+	case "j", "down":
+		ui.move(1)
+	case "k", "up":
+		ui.move(-1)
+	case "pgdn", "ctrl-f":
+		ui.move(page)
+	case "pgup", "ctrl-b":
+		ui.move(-page)
+	case "g", "home":
+		ui.move(-len(ui.view))
+	case "G", "end":
+		ui.follow = true
+		ui.selected = len(ui.view) - 1
+	case " ", "p":
+		ui.paused = !ui.paused
+		if !ui.paused {
+			pending := ui.pending
+			ui.pending = nil
+			ui.add(pending...)
+		}
+	case "/":
+		ui.searching = true
+	case "c":
+		ui.jumpToCorrelation()
+	case "esc":
+		if ui.correlation != "" {
+			ui.correlation = ""
+			ui.correlationTraces = nil
+			ui.follow = true
+		} else {
+			ui.search = ""
+		}
+		ui.status = ""
+		ui.refresh()
+	}
+
+	if ui.selected < 0 {
+		ui.selected = 0
+	}
+	return true
+}
+
+func (ui *tui) move(delta int) {
+	ui.selected += delta
+	if ui.selected >= len(ui.view) {
+		ui.selected = len(ui.view) - 1
+	}
+	if ui.selected < 0 {
+		ui.selected = 0
+	}
+	ui.follow = ui.selected == len(ui.view)-1
+}
+
+// shows the whole flow of the selected trace's correlation id
+func (ui *tui) jumpToCorrelation() {
+	if ui.selected >= len(ui.view) || ui.view[ui.selected].CorrelationId == "" {
+		ui.status = "no correlation id to jump to"
+		return
+	}
+
+	corrId := ui.view[ui.selected].CorrelationId
+	traces, err := ui.source.ByCorrelationId(corrId)
+	if err != nil {
+		ui.status = err.Error()
+		return
+	}
+
+	selectedId := ui.view[ui.selected].TraceId
+	ui.correlation = corrId
+	ui.correlationTraces = traces
+	ui.follow = false
+	ui.status = ""
+	ui.refresh()
+	for i, trc := range ui.view {
+		if trc.TraceId == selectedId {
+			ui.selected = i
+		}
+	}
+}
+
+func (ui *tui) detailHeight() int {
+	height := ui.height / 3
+	if height < 6 {
+		height = 6
+	}
+	return height
+}
+
+// rows between the header and the separator above the detail
+func (ui *tui) listHeight() int {
+	height := ui.height - ui.detailHeight() - 3
+	if height < 1 {
+		height = 1
+	}
+	return height
+}
+
+func (ui *tui) render() {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err == nil {
+		ui.width, ui.height = width, height
+	}
+
+	rows := ui.listHeight()
+	if ui.selected < ui.top {
+		ui.top = ui.selected
+	}
+	if ui.selected >= ui.top+rows {
+		ui.top = ui.selected - rows + 1
+	}
+	if ui.top > len(ui.view)-rows {
+		ui.top = len(ui.view) - rows
+	}
+	if ui.top < 0 {
+		ui.top = 0
+	}
+
+	line := 1
+	ui.line(line, "\x1b[7m", ui.header())
+	for i := ui.top; i < ui.top+rows; i++ {
+		line++
+		if i >= len(ui.view) {
+			ui.line(line, "", "")
+			continue
+		}
+		ui.traceLine(line, ui.view[i], i == ui.selected)
+	}
+
+	line++
+	ui.line(line, "\x1b[2m", strings.Repeat("─", ui.width))
+	detail := ui.detail()
+	for i := 0; i < ui.detailHeight(); i++ {
+		line++
+		text := ""
+		if i < len(detail) {
+			text = detail[i]
+		}
+		ui.line(line, "", text)
+	}
+
+	line++
+	footer := tuiHelp
+	if ui.searching {
+		footer = "/" + ui.search + "▏  Enter keep  Esc clear"
+	} else if ui.status != "" {
+		footer = ui.status
+	}
+	ui.line(line, "\x1b[2m", footer)
+	ui.out.Flush()
+}
+
+func (ui *tui) header() string {
+	header := fmt.Sprintf("TraceView  %d/%d traces", len(ui.view), len(ui.traces))
+	if ui.paused {
+		header += fmt.Sprintf("  PAUSED +%d", len(ui.pending))
+	}
+	if ui.correlation != "" {
+		header += "  correlation " + ui.correlation
+	}
+	if ui.search != "" {
+		header += "  search \"" + ui.search + "\""
+	}
+	return header
+}
+
+// writes the text at the line, cut to the width and cleared to its end
+func (ui *tui) line(n int, style string, text string) {
+	fmt.Fprintf(ui.out, "\x1b[%d;1H%s%s\x1b[K\x1b[0m", n, style, fitWidth(text, ui.width))
+}
+
+func (ui *tui) traceLine(n int, trc *tracing.Trace, selected bool) {
+	style := ""
+	if selected {
+		style = "\x1b[7m"
+	}
+
+	level := fmt.Sprintf("%-5s", strings.ToUpper(trc.Level))
+	prefix := trc.Timestamp.Local().Format("15:04:05.000") + " "
+	message := " " + trc.Message
+	text := fitWidth(prefix+level+message, ui.width)
+	if code, ok := levelColors[strings.ToLower(trc.Level)]; ok && len(text) >= len(prefix)+len(level) {
+		text = prefix + code + level + "\x1b[0m" + style + text[len(prefix)+len(level):]
+	}
+	fmt.Fprintf(ui.out, "\x1b[%d;1H%s%s\x1b[K\x1b[0m", n, style, text)
+}
+
+func (ui *tui) detail() []string {
+	if ui.selected >= len(ui.view) {
+		return []string{}
+	}
+
+	trc := ui.view[ui.selected]
+	lines := []string{
+		trc.Timestamp.Local().Format(time.RFC3339Nano) + "  " + strings.ToUpper(trc.Level) + "  " + trc.TraceId,
+		trc.Message,
+	}
+
+	ids := make([]string, 0)
+	for _, field := range [][2]string{
+		{"correlation", trc.CorrelationId},
+		{"span", trc.SpanId},
+		{"parent", trc.ParentSpanId},
+		{"source", trc.Source},
+		{"session", trc.SessionId},
+	} {
+		if field[1] != "" {
+			ids = append(ids, field[0]+" "+field[1])
+		}
+	}
+	if len(ids) > 0 {
+		lines = append(lines, strings.Join(ids, "  "))
+	}
+
+	names := make([]string, 0, len(trc.Properties))
+	for name := range trc.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		lines = append(lines, "  "+name+" = "+trc.Properties[name])
+	}
+
+	names = names[:0]
+	for name := range trc.Metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		lines = append(lines, "  "+name+" = "+strconv.FormatFloat(trc.Metrics[name], 'f', -1, 64)+" (metric)")
+	}
+
+	return lines
+}
+
+// replaces control characters and cuts the text to the width in runes
+func fitWidth(text string, width int) string {
+	runes := []rune(text)
+	for i, r := range runes {
+		if r < ' ' || r == 0x7f {
+			runes[i] = ' '
+		}
+	}
+	if width > 0 && len(runes) > width {
+		runes = runes[:width]
+	}
+	return string(runes)
+}
+
+// reads raw input and sends it as key names, e.g. up, pgdn, esc, enter or the character typed
+func readKeys(r io.Reader, keys chan<- string) {
+	defer close(keys)
+	buffer := make([]byte, 256)
+	for {
+		n, err := r.Read(buffer)
+		if err != nil {
+			return
+		}
+
+		input := buffer[:n]
+		for len(input) > 0 {
+			key, size := parseKey(input)
+			input = input[size:]
+			keys <- key
+		}
+	}
+}
+
+var escapeKeys = map[string]string{
+	"[A": "up", "[B": "down", "[C": "right", "[D": "left",
+	"[H": "home", "[F": "end", "OH": "home", "OF": "end",
+	"[1~": "home", "[4~": "end", "[5~": "pgup", "[6~": "pgdn",
+}
+
+func parseKey(input []byte) (string, int) {
+	switch input[0] {
+	case 0x1b:
+		if len(input) == 1 || (input[1] != '[' && input[1] != 'O') {
+			return "esc", 1
+		}
+		for i := 2; i < len(input); i++ {
+			if (input[i] >= 'A' && input[i] <= 'Z') || input[i] == '~' {
+				return escapeKeys[string(input[1:i+1])], i + 1
+			}
+		}
+		return "esc", len(input)
+	case '\r', '\n':
+		return "enter", 1
+	case 0x7f, 0x08:
+		return "backspace", 1
+	case 0x03:
+		return "ctrl-c", 1
+	case 0x06:
+		return "ctrl-f", 1
+	case 0x02:
+		return "ctrl-b", 1
+	}
+
+	text := string(input)
+	for _, r := range text {
+		return string(r), len(string(r))
+	}
+	return "", 1
+}